@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// The .gitattributes of the analysed revision, for the attributes marking
+// the generated and vendored files, and those which git doesn't diff
+
+// How the generated, vendored and undiffed files are counted
+const (
+	GeneratedSkip     = "skip"
+	GeneratedSeparate = "separate"
+	GeneratedCount    = "count"
+)
+
+const (
+	attributeSet   = "set"
+	attributeUnset = "unset"
+)
+
+type attributeRule struct {
+	dir        string // of the .gitattributes, empty at the root
+	pattern    []string
+	anchored   bool
+	attributes map[string]string
+}
+
+// Attributes are the rules of the .gitattributes of a tree, the deepest
+// files coming last so that they win. A nil Attributes marks nothing.
+type Attributes struct {
+	rules []attributeRule
+}
+
+// ParseAttributes reads the lines of a .gitattributes found in dir:
+//
+//	pattern attr -attr !attr attr=value
+func ParseAttributes(reader io.Reader, dir string) ([]attributeRule, error) {
+	var rules []attributeRule
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "[attr]") {
+			continue
+		}
+		pattern := fields[0]
+		rule := attributeRule{dir: dir, anchored: strings.Contains(strings.TrimSuffix(pattern, "/"), "/"), attributes: make(map[string]string)}
+		rule.pattern = strings.Split(strings.Trim(pattern, "/"), "/")
+		for _, attribute := range fields[1:] {
+			switch {
+			case strings.HasPrefix(attribute, "-"):
+				rule.attributes[attribute[1:]] = attributeUnset
+			case strings.HasPrefix(attribute, "!"):
+				rule.attributes[attribute[1:]] = ""
+			case strings.Contains(attribute, "="):
+				equal := strings.IndexByte(attribute, '=')
+				rule.attributes[attribute[:equal]] = attribute[equal+1:]
+			case attribute == "binary":
+				// the built-in macro: -diff -merge -text
+				rule.attributes["diff"] = attributeUnset
+			default:
+				rule.attributes[attribute] = attributeSet
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+func (r attributeRule) matches(name string) bool {
+	if r.dir != "" {
+		if !strings.HasPrefix(name, r.dir+"/") {
+			return false
+		}
+		name = name[len(r.dir)+1:]
+	}
+	parts := strings.Split(name, "/")
+	if !r.anchored {
+		// the name of the file at any depth
+		parts = parts[len(parts)-1:]
+	}
+	return matchSegments(r.pattern, parts, false)
+}
+
+// Get returns the state of an attribute for a file: attributeSet,
+// attributeUnset, a value, or empty when unspecified
+func (a *Attributes) Get(name, attribute string) string {
+	if a == nil {
+		return ""
+	}
+	for i := len(a.rules) - 1; i >= 0; i-- {
+		state, exists := a.rules[i].attributes[attribute]
+		if exists && a.rules[i].matches(name) {
+			return state
+		}
+	}
+	return ""
+}
+
+func isTrue(state string) bool {
+	return state == attributeSet || state == "true"
+}
+
+// Generated tells whether a file is marked linguist-generated,
+// linguist-vendored, or -diff
+func (a *Attributes) Generated(name string) bool {
+	return a.Vendored(name) || a.Get(name, "diff") == attributeUnset
+}
+
+// Vendored tells whether a file is marked linguist-generated or
+// linguist-vendored
+func (a *Attributes) Vendored(name string) bool {
+	return isTrue(a.Get(name, "linguist-generated")) || isTrue(a.Get(name, "linguist-vendored"))
+}
+
+// gitattributes collects the .gitattributes of a tree by directory
+func (s *ObjectStore) gitattributes(tree, dir string, files map[string]string) error {
+	entries, err := s.ReadTree(tree)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsTree() {
+			if err := s.gitattributes(entry.Hash, path.Join(dir, entry.Name), files); err != nil {
+				return err
+			}
+		} else if entry.Name == ".gitattributes" && !entry.IsSubmodule() {
+			files[dir] = entry.Hash
+		}
+	}
+	return nil
+}
+
+// ReadAttributes reads the .gitattributes of every directory of a revision
+func ReadAttributes(repo, rev string) (*Attributes, error) {
+	store, err := OpenObjectStore(repo)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	hash, err := store.ResolveRevision(rev)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := store.ReadCommit(hash)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]string)
+	if err := store.gitattributes(commit.Tree, "", files); err != nil {
+		return nil, err
+	}
+	dirs := make([]string, 0, len(files))
+	for dir := range files {
+		dirs = append(dirs, dir)
+	}
+	// the deeper directories override their parents
+	sort.Slice(dirs, func(i, j int) bool {
+		depthI, depthJ := strings.Count(dirs[i], "/"), strings.Count(dirs[j], "/")
+		if dirs[i] == "" || dirs[j] == "" {
+			return dirs[i] == "" && dirs[j] != ""
+		}
+		if depthI != depthJ {
+			return depthI < depthJ
+		}
+		return dirs[i] < dirs[j]
+	})
+	attributes := &Attributes{}
+	for _, dir := range dirs {
+		content, err := store.ReadBlob(files[dir])
+		if err != nil {
+			return nil, err
+		}
+		rules, err := ParseAttributes(strings.NewReader(string(content)), dir)
+		if err != nil {
+			return nil, err
+		}
+		attributes.rules = append(attributes.rules, rules...)
+	}
+	return attributes, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAttributes(t *testing.T) {
+	root, err := ParseAttributes(strings.NewReader("# generated\n*.pb.go linguist-generated\nthird_party/** linguist-vendored\n*.png binary\n/src/parser.c linguist-generated=true\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested, _ := ParseAttributes(strings.NewReader("keep.c -linguist-vendored\n"), "third_party/zlib")
+	attributes := &Attributes{rules: append(root, nested...)}
+
+	cases := []struct {
+		name      string
+		generated bool
+	}{
+		{"api/service.pb.go", true},
+		{"third_party/zlib/inflate.c", true},
+		{"third_party/zlib/keep.c", false},
+		{"doc/logo.png", true},
+		{"src/parser.c", true},
+		{"lib/src/parser.c", false},
+		{"src/main.c", false},
+	}
+	for _, c := range cases {
+		if attributes.Generated(c.name) != c.generated {
+			t.Errorf("%v should be generated: %v", c.name, c.generated)
+		}
+	}
+	if attributes.Get("doc/logo.png", "diff") != attributeUnset {
+		t.Errorf("The binary macro should unset diff")
+	}
+	var none *Attributes
+	if none.Generated("api/service.pb.go") {
+		t.Errorf("Without attributes nothing is generated")
+	}
+}
+
+func TestGeneratedHistory(t *testing.T) {
+	log := "Contributor1|Mon May 30 22:08:53 2016 +0200\n\n2\t1\tsrc/main.c\n10\t0\tapi/service.pb.go\n"
+	rules, _ := ParseAttributes(strings.NewReader("*.pb.go linguist-generated\n"), "")
+
+	parser := NewParser("/", *NewPeriodArray(), *NewUserArray())
+	parser.SetAttributes(&Attributes{rules: rules})
+	parser.ParseHistory(strings.NewReader(log))
+	if parser.Report.TotalAdditions != 2 || parser.Report.TotalGenerated != 0 {
+		t.Errorf("The generated files should be skipped by default")
+	}
+
+	parser = NewParser("/", *NewPeriodArray(), *NewUserArray())
+	parser.Generated = GeneratedSeparate
+	parser.SetAttributes(&Attributes{rules: rules})
+	parser.ParseHistory(strings.NewReader(log))
+	contrib := parser.Report.Contributors["Contributor1"].Contributions[0]
+	if contrib.Additions != 2 || contrib.GeneratedAdditions != 10 || parser.Report.TotalGenerated != 10 || parser.Report.TotalCommits != 1 {
+		t.Errorf("The generated files should be reported apart: %v", contrib)
+	}
+
+	parser = NewParser("/", *NewPeriodArray(), *NewUserArray())
+	parser.Generated = GeneratedCount
+	parser.SetAttributes(&Attributes{rules: rules})
+	parser.ParseHistory(strings.NewReader(log))
+	if parser.Report.TotalAdditions != 12 {
+		t.Errorf("The generated files should be counted as the others")
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Binary files have no line counts, their changes are counted apart: the
+// files touched and the bytes written, the size of the new content or of
+// the deleted one
+
+// BlobSizer tells the size of the blobs of a repository
+type BlobSizer interface {
+	BlobSize(hash string) (int, error)
+}
+
+// BlobSize reads the blob, the built-in reader has no cheaper way
+func (s *ObjectStore) BlobSize(hash string) (int, error) {
+	content, err := s.ReadBlob(hash)
+	return len(content), err
+}
+
+// CatFile looks the blobs up with a single git cat-file process
+type CatFile struct {
+	command *exec.Cmd
+	in      io.WriteCloser
+	out     *bufio.Reader
+}
+
+func StartCatFile(repo string) (*CatFile, error) {
+	command := exec.Command("git", "-C", repo, "cat-file", "--batch-check=%(objectname) %(objecttype) %(objectsize)")
+	in, err := command.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := command.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := command.Start(); err != nil {
+		return nil, err
+	}
+	return &CatFile{command: command, in: in, out: bufio.NewReader(out)}, nil
+}
+
+func (c *CatFile) BlobSize(hash string) (int, error) {
+	if _, err := fmt.Fprintln(c.in, hash); err != nil {
+		return 0, err
+	}
+	line, err := c.out.ReadString('\n')
+	if err != nil {
+		return 0, err
+	}
+	// <hash> blob <size>, or <hash> missing
+	fields := strings.Fields(line)
+	if len(fields) != 3 || fields[1] != "blob" {
+		return 0, fmt.Errorf("git cat-file: %v is not a blob", hash)
+	}
+	return strconv.Atoi(fields[2])
+}
+
+func (c *CatFile) Close() error {
+	c.in.Close()
+	return c.command.Wait()
+}
+
+// binarySize returns the bytes written by the change of a binary file, 0
+// when the log has no raw lines or the blob can't be read
+func (p *Parser) binarySize(file FileStat) int {
+	hash := file.NewBlob
+	if hash == nullHash {
+		hash = file.OldBlob
+	}
+	if p.blobSizes == nil || hash == "" || hash == nullHash {
+		return 0
+	}
+	size, err := p.blobSizes.BlobSize(hash)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// creditBinary credits the change of a binary file, which only makes a
+// commit on its own when the binary commits are credited
+func (c commitCredit) creditBinary(report *Report, file FileStat, first bool, periodMap map[string][]PeriodTS) {
+	for _, contributor := range c.contributors {
+		report.AddContributor(contributor, periodMap)
+	}
+	if first {
+		report.IncrementSharedCommit(c.contributors, c.split, c.date)
+	}
+	report.IncrementBinary(c.contributors, 1, file.Size, c.split, c.date)
+}
+
+func (r *Report) IncrementBinary(names []string, files, bytes int, split bool, date time.Time) {
+	for index, name := range names {
+		if !r.HasContributor(name) {
+			continue
+		}
+		contribFiles, contribBytes := files, bytes
+		if split {
+			contribFiles = splitShare(files, len(names), index)
+			contribBytes = splitShare(bytes, len(names), index)
+		}
+		contrib := GetContribution(r.Contributors[name].Contributions, date)
+		contrib.BinaryFiles += contribFiles
+		contrib.BinaryBytes += contribBytes
+	}
+	r.TotalBinaryFiles += files
+	r.TotalBinaryBytes += bytes
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+type fakeSizes map[string]int
+
+func (f fakeSizes) BlobSize(hash string) (int, error) {
+	return f[hash], nil
+}
+
+func TestRawLines(t *testing.T) {
+	log := "Contributor1|Mon May 30 22:08:53 2016 +0200\n\n" +
+		":100644 100644 1111111111111111111111111111111111111111 2222222222222222222222222222222222222222 M\tlogo.png\n" +
+		":100644 100644 3333333333333333333333333333333333333333 4444444444444444444444444444444444444444 R097\tsrc/a.c\tlib/a.c\n" +
+		"-\t-\tlogo.png\n1\t1\t{src => lib}/a.c\n"
+	commit, err := NewHistoryScanner(strings.NewReader(log)).Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commit.Files) != 2 {
+		t.Fatalf("The raw lines should not be files: %v", commit.Files)
+	}
+	if logo := commit.Files[0]; !logo.Binary || logo.OldBlob != strings.Repeat("1", 40) || logo.NewBlob != strings.Repeat("2", 40) {
+		t.Errorf("Unexpected binary file %v", logo)
+	}
+	if moved := commit.Files[1]; moved.Path != "lib/a.c" || moved.NewBlob != strings.Repeat("4", 40) {
+		t.Errorf("The blobs of a rename should go with its destination: %v", moved)
+	}
+}
+
+func TestBinaryFiles(t *testing.T) {
+	log := "Contributor1|Mon May 30 22:08:53 2016 +0200\n\n" +
+		":000000 100644 " + nullHash + " aaaa A\tlogo.png\n-\t-\tlogo.png\n" +
+		"Contributor2|Mon May 30 22:08:53 2016 +0200\n\n" +
+		":100644 000000 aaaa " + nullHash + " D\tlogo.png\n-\t-\tlogo.png\n3\t0\tmain.c\n"
+
+	parser := NewParser("/", *NewPeriodArray(), *NewUserArray())
+	parser.SetBlobSizer(fakeSizes{"aaaa": 1200})
+	parser.ParseHistory(strings.NewReader(log))
+	report := parser.Report
+	first := report.Contributors["Contributor1"].Contributions[0]
+	if first.BinaryFiles != 1 || first.BinaryBytes != 1200 || first.Commits != 0 {
+		t.Errorf("A binary commit should be counted apart: %v", first)
+	}
+	second := report.Contributors["Contributor2"].Contributions[0]
+	if second.BinaryBytes != 1200 || second.Commits != 1 || report.TotalBinaryFiles != 2 || report.TotalCommits != 1 {
+		t.Errorf("The size of the deleted file should count: %v", second)
+	}
+
+	parser = NewParser("/", *NewPeriodArray(), *NewUserArray())
+	parser.BinaryCommits = true
+	parser.ParseHistory(strings.NewReader(log))
+	if report := parser.Report; report.TotalCommits != 2 || report.Contributors["Contributor1"].Contributions[0].Commits != 1 || report.TotalBinaryBytes != 0 {
+		t.Errorf("The binary commit should be counted, the sizes being unknown")
+	}
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/ttacon/chalk"
+	"io"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BlameLine is the author of blamed lines and when they wrote them, so that
+// the lines are credited to the period they belong to
+type BlameLine struct {
+	Identity
+	Time int64 // author-time, in seconds since the epoch
+}
+
+// Date returns the author time of the lines, the zero time when unknown
+func (l BlameLine) Date() time.Time {
+	if l.Time == 0 {
+		return time.Time{}
+	}
+	return time.Unix(l.Time, 0)
+}
+
+// BlameCounts maps an author and author time to the number of lines they own
+type BlameCounts map[BlameLine]int
+
+func (b BlameCounts) Merge(other BlameCounts) {
+	for author, lines := range other {
+		b[author] += lines
+	}
+}
+
+type BlameFileTiming struct {
+	Path     string
+	Duration time.Duration
+}
+
+// FileBlame is the lines per author of a file, its path being prefixed with
+// the one of its submodule
+type FileBlame struct {
+	Path   string
+	Counts BlameCounts
+}
+
+// BlameResult holds the lines per author of a blame stage, in total and by
+// file, along with the time spent on each file
+type BlameResult struct {
+	Counts  BlameCounts
+	Files   []FileBlame
+	Timings []BlameFileTiming
+}
+
+// Slowest returns the n files that took the longest to blame
+func (r *BlameResult) Slowest(n int) []BlameFileTiming {
+	timings := append([]BlameFileTiming(nil), r.Timings...)
+	sort.SliceStable(timings, func(i, j int) bool { return timings[i].Duration > timings[j].Duration })
+	if n < len(timings) {
+		timings = timings[:n]
+	}
+	return timings
+}
+
+func runGit(repo string, args ...string) ([]byte, error) {
+	command := exec.Command("git", append([]string{"-C", repo}, args...)...)
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+	out, err := command.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %v: %v: %v", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// ListFiles returns every blob in the tree of a revision, named after its path
+func ListFiles(repo, rev string) ([]TreeEntry, error) {
+	out, err := runGit(repo, "ls-tree", "-r", "-z", rev, "--")
+	if err != nil {
+		return nil, err
+	}
+	var files []TreeEntry
+	for _, entry := range strings.Split(string(out), "\x00") {
+		// <mode> SP <type> SP <object> TAB <file>
+		tab := strings.IndexByte(entry, '\t')
+		if tab < 0 {
+			continue
+		}
+		fields := strings.Fields(entry[:tab])
+		if len(fields) == 3 && fields[1] == "blob" {
+			mode, _ := strconv.ParseUint(fields[0], 8, 32)
+			files = append(files, TreeEntry{Mode: uint32(mode), Name: entry[tab+1:], Hash: fields[2]})
+		}
+	}
+	return files, nil
+}
+
+// parseBlamePorcelain counts the lines of each author in the output of
+// `git blame --line-porcelain`, leaving out the boundary lines that come
+// from before the selected revisions
+func parseBlamePorcelain(reader io.Reader) (BlameCounts, error) {
+	counts := make(BlameCounts)
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var author BlameLine
+	boundary := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		// the content of the file is prefixed with a tab and closes the headers of the line
+		if strings.HasPrefix(line, "\t") {
+			if !boundary && author.Name != "" {
+				counts[author]++
+			}
+			author = BlameLine{}
+			boundary = false
+		} else if strings.HasPrefix(line, "author ") {
+			author.Name = strings.TrimPrefix(line, "author ")
+		} else if strings.HasPrefix(line, "author-mail ") {
+			author.Email = strings.Trim(strings.TrimPrefix(line, "author-mail "), "<>")
+		} else if strings.HasPrefix(line, "author-time ") {
+			author.Time, _ = strconv.ParseInt(strings.TrimPrefix(line, "author-time "), 10, 64)
+		} else if line == "boundary" {
+			boundary = true
+		}
+	}
+	return counts, scanner.Err()
+}
+
+// BlameFile returns the number of lines each author owns in a file, args
+// selecting the revisions as given by Revisions.BlameArgs
+func BlameFile(ctx context.Context, repo string, args []string, path string) (BlameCounts, error) {
+	command := exec.CommandContext(ctx, "git", append(append([]string{"-C", repo, "blame", "--line-porcelain"}, args...), "--", path)...)
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+	stdout, err := command.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := command.Start(); err != nil {
+		return nil, err
+	}
+	counts, parseErr := parseBlamePorcelain(stdout)
+	if err := command.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("git blame %v: %v: %v", path, err, strings.TrimSpace(stderr.String()))
+	}
+	return counts, parseErr
+}
+
+// LastCommits returns the last commit changing each of the paths in the
+// history blamed at rev, the merges counting for the changes they bring to
+// their first parent. The walk stops once every path is found, the paths
+// left unchanged since the start of a range being missing.
+func LastCommits(ctx context.Context, repo string, revs Revisions, rev string, paths []string) (map[string]string, error) {
+	last := make(map[string]string)
+	if len(paths) == 0 {
+		return last, nil
+	}
+	wanted := make(map[string]bool)
+	for _, name := range paths {
+		wanted[name] = true
+	}
+	// with -z the paths are neither quoted nor escaped
+	args := []string{"-C", repo, "log", "-z", "--format=%x00%H", "--name-only", "--no-renames", "--diff-merges=first-parent"}
+	if revs.FirstParent {
+		args = append(args, "--first-parent")
+	}
+	if revs.Range != "" {
+		start, _ := revs.SplitRange()
+		args = append(args, start+".."+rev)
+	} else {
+		args = append(args, rev)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	command := exec.CommandContext(ctx, "git", append(args, "--")...)
+	out, err := command.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := command.Start(); err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(out)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	scanner.Split(scanNul)
+	// each commit is an empty field, its hash, then its paths, the first one
+	// after a newline
+	var commit string
+	header, first := false, false
+	for len(last) < len(wanted) && scanner.Scan() {
+		field := scanner.Text()
+		if first {
+			field, first = strings.TrimPrefix(field, "\n"), false
+		}
+		switch {
+		case field == "":
+			header = true
+		case header:
+			commit, header, first = field, false, true
+		case wanted[field] && last[field] == "":
+			last[field] = commit
+		}
+	}
+	if len(last) == len(wanted) {
+		// the rest of the history is not needed
+		cancel()
+		command.Wait()
+		return last, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := command.Wait(); err != nil {
+		return nil, fmt.Errorf("git log: %v", err)
+	}
+	return last, nil
+}
+
+// scanNul splits the output of git -z into its NUL terminated fields
+func scanNul(data []byte, atEOF bool) (int, []byte, error) {
+	if end := bytes.IndexByte(data, 0); end >= 0 {
+		return end + 1, data[:end], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+type blameJob struct {
+	index int
+	file  TreeEntry
+}
+
+type blameFileResult struct {
+	counts   BlameCounts
+	duration time.Duration
+	err      error
+}
+
+// BlameFiles blames the selected files accepted by the filter with a pool of
+// jobs workers, unless their blob is found in the cache. The results are
+// merged in the order of the tree whatever the order the workers finish in.
+// The filter is given the paths in the repository, the timings show them
+// under the prefix of submodules.
+func BlameFiles(ctx context.Context, repo string, options Options, filter func(path string) bool, cache *BlameCache) (*BlameResult, error) {
+	prefix, jobs := options.Prefix, options.Jobs
+	rev, err := options.Revisions.BlameRevision(repo)
+	if err != nil {
+		return nil, err
+	}
+	if rev == "" {
+		// the window has no commit, so no line
+		return &BlameResult{Counts: make(BlameCounts)}, nil
+	}
+	files, err := ListFiles(repo, rev)
+	if err != nil {
+		return nil, err
+	}
+	args := append(options.Diff.BlameArgs(), options.Revisions.BlameArgs(rev)...)
+	var selected []TreeEntry
+	for _, file := range files {
+		if filter(file.Name) {
+			selected = append(selected, file)
+		}
+	}
+	if jobs < 1 {
+		jobs = 1
+	}
+	// the blame of a file depends on its history as well as its content
+	var lastCommits map[string]string
+	if cache != nil {
+		names := make([]string, len(selected))
+		for index, file := range selected {
+			names[index] = file.Name
+		}
+		if lastCommits, err = LastCommits(ctx, repo, options.Revisions, rev, names); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]blameFileResult, len(selected))
+	queue := make(chan blameJob)
+	var workers sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for job := range queue {
+				start := time.Now()
+				commit := lastCommits[job.file.Name]
+				counts, cached := cache.Get(job.file.Name, job.file.Hash, commit)
+				var err error
+				if !cached {
+					counts, err = BlameFile(ctx, repo, args, job.file.Name)
+					if err == nil {
+						cache.Put(job.file.Name, job.file.Hash, commit, counts)
+					}
+				}
+				results[job.index] = blameFileResult{counts: counts, duration: time.Since(start), err: err}
+			}
+		}()
+	}
+feed:
+	for index, file := range selected {
+		select {
+		case queue <- blameJob{index: index, file: file}:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	workers.Wait()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	result := &BlameResult{Counts: make(BlameCounts)}
+	for index, fileResult := range results {
+		result.Timings = append(result.Timings, BlameFileTiming{Path: path.Join(prefix, selected[index].Name), Duration: fileResult.duration})
+		if fileResult.err != nil {
+			fmt.Println(chalk.Yellow, "Skip blame: ", fileResult.err)
+			continue
+		}
+		result.Files = append(result.Files, FileBlame{Path: path.Join(prefix, selected[index].Name), Counts: fileResult.counts})
+		result.Counts.Merge(fileResult.counts)
+	}
+	return result, nil
+}
+
+// counted tells whether a file of the repository is blamed along with the
+// others, the generated ones being left out unless they are counted
+func (o Options) counted(name string) bool {
+	return o.Paths.Match(path.Join(o.Prefix, name)) && (o.Generated == GeneratedCount || !o.attributes.Generated(name))
+}
+
+func ExecGitBlameRaw(ctx context.Context, repo string, options Options, cache *BlameCache) (*BlameResult, error) {
+	fmt.Println("Gathering the stats in the repo (2/3)", repo)
+	return BlameFiles(ctx, repo, options, options.counted, cache)
+}
+
+// SelectBlame keeps the files of the raw blame stage which are part of the
+// selected one, rather than blaming them a second time
+func SelectBlame(repo string, raw *BlameResult, options Options) *BlameResult {
+	fmt.Println("Gathering the stats in the repo (3/3)", repo)
+	result := &BlameResult{Counts: make(BlameCounts)}
+	for _, file := range raw.Files {
+		if options.Paths.MatchSelected(file.Path) {
+			result.Files = append(result.Files, file)
+			result.Counts.Merge(file.Counts)
+		}
+	}
+	return result
+}
+
+// ExecGitBlameGenerated blames the generated files for -generated=separate
+func ExecGitBlameGenerated(ctx context.Context, repo string, options Options, cache *BlameCache) (*BlameResult, error) {
+	fmt.Println("Gathering the stats of the generated files in the repo", repo)
+	return BlameFiles(ctx, repo, options, func(name string) bool {
+		return options.Paths.Match(path.Join(options.Prefix, name)) && options.attributes.Generated(name)
+	}, cache)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPorcelain = `0123456789012345678901234567890123456789 1 1 2
+author Contributor One
+author-mail <one@example.com>
+author-time 1464638933
+author-tz +0200
+summary First
+filename odd name.c
+	author Not A Header
+0123456789012345678901234567890123456789 2 2
+author Contributor One
+author-mail <one@example.com>
+author-time 1464638933
+author-tz +0200
+summary First
+filename odd name.c
+	second line
+9876543210987654321098765432109876543210 3 3 1
+author Contributor Two
+author-mail <two@example.com>
+author-time 1464638999
+author-tz +0200
+summary Second
+filename odd name.c
+	third line
+1111111111111111111111111111111111111111 4 4 1
+author Contributor Three
+author-mail <three@example.com>
+author-time 1264638999
+author-tz +0200
+summary Older than the selection
+boundary
+filename odd name.c
+	fourth line
+`
+
+func TestParseBlamePorcelain(t *testing.T) {
+	counts, err := parseBlamePorcelain(strings.NewReader(testPorcelain))
+	if err != nil {
+		t.Errorf("Parsing a valid blame should not fail: %v", err)
+	}
+	if len(counts) != 2 {
+		t.Errorf("There should be 2 authors, the boundary lines being left out, and there were %v", len(counts))
+	}
+	if counts[BlameLine{Identity{"Contributor One", "one@example.com"}, 1464638933}] != 2 || counts[BlameLine{Identity{"Contributor Two", "two@example.com"}, 1464638999}] != 1 {
+		t.Errorf("Unexpected line counts %v", counts)
+	}
+}
+
+func TestAddBlameCounts(t *testing.T) {
+	report := NewReport()
+	report.AddContributor("Contributor1", make(map[string][]PeriodTS))
+	users := UserArray{Users: []User{{Alias: "alias1", Name: "Contributor1"}, {Alias: "skipped", Name: ""}}}
+	addBlameCounts(BlameCounts{{Identity: Identity{Name: "alias1"}}: 10, {Identity: Identity{Name: "skipped"}}: 5}, false, report, NewIdentities(users), nil)
+	if report.Contributors["Contributor1"].Contributions[0].OwnedLines != 10 {
+		t.Errorf("The blamed lines of an alias should go to the aliased contributor")
+	}
+	if report.TotalOwnedLines != 10 {
+		t.Errorf("The lines of skipped users should not be counted")
+	}
+	if report.TotalAdditions != 0 {
+		t.Errorf("The blamed lines should not count as additions")
+	}
+	addBlameCounts(BlameCounts{{Identity: Identity{Name: "alias1"}}: 4}, true, report, NewIdentities(users), nil)
+	if report.Contributors["Contributor1"].Contributions[0].SelectedOwnedLines != 4 || report.TotalOwnedLines != 10 {
+		t.Errorf("The lines of the selected files should be owned apart")
+	}
+	addBlameCounts(BlameCounts{{Identity: Identity{Name: "Contributor2"}}: 6}, false, report, NewIdentities(users), nil)
+	if !report.HasContributor("Contributor2") || report.Contributors["Contributor2"].Contributions[0].OwnedLines != 6 || report.TotalOwnedLines != 16 {
+		t.Errorf("The owners without any commit in the history should be counted too")
+	}
+}
+
+func TestAddGeneratedBlameCounts(t *testing.T) {
+	report := NewReport()
+	report.AddContributor("Contributor1", nil)
+	report.IncrementGenerated([]string{"Contributor1"}, 4, 0, true, time.Time{})
+	addGeneratedBlameCounts(BlameCounts{{Identity: Identity{Name: "Contributor1"}}: 4}, report, NewIdentities(*NewUserArray()), nil)
+	contrib := report.Contributors["Contributor1"].Contributions[0]
+	if contrib.GeneratedAdditions != 4 || report.TotalGenerated != 4 || contrib.GeneratedOwnedLines != 4 || report.TotalGeneratedOwnedLines != 4 {
+		t.Errorf("The generated lines owned should be counted apart from the generated additions")
+	}
+}
+
+func TestAddBlameCountsPeriods(t *testing.T) {
+	report := NewReport()
+	telecom := NewPeriodTS(Period{User: "Contributor1", Start: "2015-01-01", End: "2016-01-01", Alias: "working at telecom"})
+	motionSpell := NewPeriodTS(Period{User: "Contributor1", Start: "2016-01-01", End: "2017-01-01", Alias: "working at Motion Spell"})
+	report.AddContributor("Contributor1", map[string][]PeriodTS{"Contributor1": {*telecom, *motionSpell}})
+	author := Identity{Name: "Contributor1"}
+	// 2015-06-01, 2016-05-30 and 2014-01-01
+	addBlameCounts(BlameCounts{{author, 1433116800}: 3, {author, 1464638933}: 5, {author, 1388534400}: 2}, false, report, NewIdentities(*NewUserArray()), nil)
+	contributions := report.Contributors["Contributor1"].Contributions
+	if contributions[0].OwnedLines != 2 || contributions[1].OwnedLines != 3 || contributions[2].OwnedLines != 5 {
+		t.Errorf("The blamed lines should go to the period they were written in, got %v %v %v", contributions[0].OwnedLines, contributions[1].OwnedLines, contributions[2].OwnedLines)
+	}
+}
+
+func TestSlowest(t *testing.T) {
+	result := &BlameResult{Timings: []BlameFileTiming{{"a", time.Second}, {"b", 3 * time.Second}, {"c", 2 * time.Second}}}
+	slowest := result.Slowest(2)
+	if len(slowest) != 2 || slowest[0].Path != "b" || slowest[1].Path != "c" {
+		t.Errorf("The slowest files should be b and c, got %v", slowest)
+	}
+	if result.Timings[0].Path != "a" {
+		t.Errorf("Slowest should not reorder the timings of the result")
+	}
+	if len(result.Slowest(10)) != 3 {
+		t.Errorf("Asking for more files than blamed should return all of them")
+	}
+}
+
+func TestSelectBlame(t *testing.T) {
+	one := BlameLine{Identity: Identity{Name: "Contributor1"}}
+	raw := &BlameResult{Files: []FileBlame{{Path: "lib/main.c", Counts: BlameCounts{one: 3}}, {Path: "lib/main.go", Counts: BlameCounts{one: 5}}}}
+	selected := SelectBlame("repo", raw, Options{Paths: PathFilter{Selected: []string{"*.c"}}})
+	if len(selected.Files) != 1 || selected.Files[0].Path != "lib/main.c" || selected.Counts[one] != 3 {
+		t.Errorf("Only the selected files of the raw stage should be kept, got %v", selected.Files)
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// On-disk cache of the history and blame stages under .git/git-stats/: the
+// numstat of a commit never changes, nor does the blame of a file as long as
+// its content, the last commit changing it and the options stay the same
+
+const cacheDirName = "git-stats"
+
+// blameCacheVersion changes with the content of the blame entries
+const blameCacheVersion = "3"
+
+func cacheDir(repo string) (string, error) {
+	gitDir, err := FindGitDir(repo)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(gitDir, cacheDirName)
+	return dir, os.MkdirAll(dir, 0755)
+}
+
+// cacheFileName names a cache file after the options shaping its content, so
+// that runs with other options don't share it
+func cacheFileName(kind string, options []string) string {
+	sum := sha1.Sum([]byte(strings.Join(options, "\x00")))
+	return fmt.Sprintf("%v-%x", kind, sum[:6])
+}
+
+type cacheRecord struct {
+	offset int64
+	size   int64
+}
+
+// HistoryCache stores the log output of each commit, its header and numstat
+// lines, in an append only file of records:
+//
+//	commit <hash> <size>
+//	<size bytes of log output>
+type HistoryCache struct {
+	file  *os.File
+	end   int64
+	index map[string]cacheRecord
+}
+
+func OpenHistoryCache(repo string, options []string) (*HistoryCache, error) {
+	dir, err := cacheDir(repo)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, cacheFileName("history", options)), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	cache := &HistoryCache{file: file, index: make(map[string]cacheRecord)}
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		var hash string
+		var size int64
+		if _, err := fmt.Sscanf(line, "commit %s %d\n", &hash, &size); err != nil {
+			break
+		}
+		if discarded, _ := reader.Discard(int(size)); int64(discarded) != size {
+			break
+		}
+		cache.index[hash] = cacheRecord{offset: cache.end + int64(len(line)), size: size}
+		cache.end += int64(len(line)) + size
+	}
+	// drop what an interrupted run may have left half written
+	if err := file.Truncate(cache.end); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return cache, nil
+}
+
+func (c *HistoryCache) Has(hash string) bool {
+	_, exists := c.index[hash]
+	return exists
+}
+
+// Get returns the log output of a commit, false if it isn't cached
+func (c *HistoryCache) Get(hash string) (string, bool, error) {
+	record, exists := c.index[hash]
+	if !exists {
+		return "", false, nil
+	}
+	block := make([]byte, record.size)
+	if _, err := c.file.ReadAt(block, record.offset); err != nil {
+		return "", false, err
+	}
+	return string(block), true, nil
+}
+
+func (c *HistoryCache) Put(hash, block string) error {
+	header := fmt.Sprintf("commit %v %v\n", hash, len(block))
+	if _, err := c.file.WriteAt([]byte(header+block), c.end); err != nil {
+		return err
+	}
+	c.index[hash] = cacheRecord{offset: c.end + int64(len(header)), size: int64(len(block))}
+	c.end += int64(len(header) + len(block))
+	return nil
+}
+
+// Fill splits a log output into commits and stores them
+func (c *HistoryCache) Fill(reader io.Reader) error {
+	lines := bufio.NewReaderSize(reader, 64*1024)
+	var hash string
+	var block strings.Builder
+	for {
+		line, err := lines.ReadString('\n')
+		if trimmed := strings.TrimRight(line, "\r\n"); isCommitHeader(trimmed) {
+			if hash != "" {
+				if err := c.Put(hash, block.String()); err != nil {
+					return err
+				}
+			}
+			hash = parseCommitHeader(trimmed).Hash
+			block.Reset()
+		}
+		block.WriteString(line)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if hash == "" {
+		return nil
+	}
+	return c.Put(hash, block.String())
+}
+
+// Replay writes the log output of the commits read from hashes, one per
+// line, which must all be cached
+func (c *HistoryCache) Replay(hashes io.Reader, writer io.Writer) error {
+	scanner := bufio.NewScanner(hashes)
+	for scanner.Scan() {
+		hash := scanner.Text()
+		record, exists := c.index[hash]
+		if !exists {
+			return fmt.Errorf("commit %v is missing from the history cache", hash)
+		}
+		if _, err := io.Copy(writer, io.NewSectionReader(c.file, record.offset, record.size)); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (c *HistoryCache) Close() error {
+	return c.file.Close()
+}
+
+// BlameCache stores the lines per author of the blamed files, by path, blob
+// and last commit changing the file, since reverting a file brings back a
+// former blob whose lines belong to whom reverted it. Only the files of the
+// latest run are kept. A nil cache stores nothing.
+type BlameCache struct {
+	path    string
+	mutex   sync.Mutex
+	entries map[string]BlameCounts
+	used    map[string]bool
+	dirty   bool
+}
+
+type blameCacheAuthor struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Time  int64  `json:"time"`
+	Lines int    `json:"lines"`
+}
+
+type blameCacheEntry struct {
+	Path    string             `json:"path"`
+	Blob    string             `json:"blob"`
+	Commit  string             `json:"commit"`
+	Authors []blameCacheAuthor `json:"authors"`
+}
+
+func blameCacheKey(path, blob, commit string) string {
+	return path + "\x00" + blob + "\x00" + commit
+}
+
+func OpenBlameCache(repo string, options []string) (*BlameCache, error) {
+	dir, err := cacheDir(repo)
+	if err != nil {
+		return nil, err
+	}
+	cache := &BlameCache{path: filepath.Join(dir, cacheFileName("blame", append([]string{blameCacheVersion}, options...))), entries: make(map[string]BlameCounts), used: make(map[string]bool)}
+	content, err := ioutil.ReadFile(cache.path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []blameCacheEntry
+	if err := json.Unmarshal(content, &entries); err != nil {
+		// a damaged cache is rebuilt
+		return cache, nil
+	}
+	for _, entry := range entries {
+		counts := make(BlameCounts)
+		for _, author := range entry.Authors {
+			counts[BlameLine{Identity: Identity{Name: author.Name, Email: author.Email}, Time: author.Time}] = author.Lines
+		}
+		cache.entries[blameCacheKey(entry.Path, entry.Blob, entry.Commit)] = counts
+	}
+	return cache, nil
+}
+
+func (c *BlameCache) Get(path, blob, commit string) (BlameCounts, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	key := blameCacheKey(path, blob, commit)
+	counts, exists := c.entries[key]
+	if exists {
+		c.used[key] = true
+	}
+	return counts, exists
+}
+
+func (c *BlameCache) Put(path, blob, commit string, counts BlameCounts) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	key := blameCacheKey(path, blob, commit)
+	c.entries[key] = counts
+	c.used[key] = true
+	c.dirty = true
+}
+
+// Save writes the cache if files were blamed or left out since it was opened
+func (c *BlameCache) Save() error {
+	if c == nil || (!c.dirty && len(c.used) == len(c.entries)) {
+		return nil
+	}
+	entries := make([]blameCacheEntry, 0, len(c.used))
+	for key := range c.used {
+		counts := c.entries[key]
+		parts := strings.SplitN(key, "\x00", 3)
+		entry := blameCacheEntry{Path: parts[0], Blob: parts[1], Commit: parts[2]}
+		for author, lines := range counts {
+			entry.Authors = append(entry.Authors, blameCacheAuthor{Name: author.Name, Email: author.Email, Time: author.Time, Lines: lines})
+		}
+		entries = append(entries, entry)
+	}
+	content, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	// replace the file at once, an interrupted run leaves the former one
+	temporary := c.path + ".tmp"
+	if err := ioutil.WriteFile(temporary, content, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(temporary, c.path); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHistoryCache(t *testing.T) {
+	repo, err := ioutil.TempDir("", "git-stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(repo)
+	// a bare repository
+	os.Mkdir(filepath.Join(repo, "objects"), 0755)
+
+	first := "'Contributor1|Mon May 30 22:08:53 2016 +0200||one@example.com|Contributor1|one@example.com|Mon May 30 22:08:53 2016 +0200|aaaa'\n\n5\t1\tgit-stats.go\n"
+	second := "'Contributor2|Tue May 31 10:00:00 2016 +0200||two@example.com|Contributor2|two@example.com|Tue May 31 10:00:00 2016 +0200|bbbb'\n"
+	cache, err := OpenHistoryCache(repo, historyOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Fill(strings.NewReader(second + first)); err != nil {
+		t.Fatal(err)
+	}
+	cache.Close()
+
+	cache, err = OpenHistoryCache(repo, historyOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cache.Has("aaaa") || !cache.Has("bbbb") || cache.Has("cccc") {
+		t.Errorf("The cached commits should be found again")
+	}
+	var history strings.Builder
+	if err := cache.Replay(strings.NewReader("aaaa\nbbbb\n"), &history); err != nil {
+		t.Fatal(err)
+	}
+	if history.String() != first+second {
+		t.Errorf("The history should be replayed in the given order, got %q", history.String())
+	}
+	if err := cache.Replay(strings.NewReader("cccc\n"), ioutil.Discard); err == nil {
+		t.Errorf("Replaying a commit missing from the cache should fail")
+	}
+	end := cache.end
+	cache.Close()
+
+	// an interrupted run leaves a partial record
+	file, _ := os.OpenFile(filepath.Join(repo, cacheDirName, cacheFileName("history", historyOptions)), os.O_WRONLY|os.O_APPEND, 0644)
+	file.WriteString("commit cccc 120\n'Contributor3|")
+	file.Close()
+	cache, err = OpenHistoryCache(repo, historyOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	if cache.Has("cccc") || cache.end != end {
+		t.Errorf("The partial record should be dropped")
+	}
+	if block, cached, _ := cache.Get("bbbb"); !cached || block != second {
+		t.Errorf("The records before the partial one should be kept, got %q", block)
+	}
+}
+
+func TestBlameCache(t *testing.T) {
+	repo, err := ioutil.TempDir("", "git-stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(repo)
+	os.Mkdir(filepath.Join(repo, "objects"), 0755)
+
+	var missing *BlameCache
+	if _, cached := missing.Get("git-stats.go", "aaaa", "1111"); cached {
+		t.Errorf("A nil cache should hold nothing")
+	}
+
+	options := []string{"--root", "--since=2016-01-01"}
+	cache, err := OpenBlameCache(repo, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := BlameCounts{{Identity: Identity{Name: "Contributor1", Email: "one@example.com"}, Time: 1464638933}: 12}
+	cache.Put("git-stats.go", "aaaa", "1111", counts)
+	cache.Put("Readme.md", "bbbb", "1111", BlameCounts{{Identity: Identity{Name: "Contributor2"}}: 3})
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, _ = OpenBlameCache(repo, options)
+	if cached, exists := cache.Get("git-stats.go", "aaaa", "1111"); !exists || cached[BlameLine{Identity{Name: "Contributor1", Email: "one@example.com"}, 1464638933}] != 12 {
+		t.Errorf("The blame should be found again, got %v", cached)
+	}
+	if _, exists := cache.Get("git-stats.go", "cccc", "1111"); exists {
+		t.Errorf("Another content of the file should be blamed again")
+	}
+	if _, exists := cache.Get("git-stats.go", "aaaa", "2222"); exists {
+		t.Errorf("The same content brought back by another commit should be blamed again")
+	}
+	cache.Save()
+
+	cache, _ = OpenBlameCache(repo, options)
+	if _, exists := cache.Get("Readme.md", "bbbb", "1111"); exists {
+		t.Errorf("The files left out of the latest run should be dropped")
+	}
+	other, _ := OpenBlameCache(repo, []string{"--root"})
+	if _, exists := other.Get("git-stats.go", "aaaa", "1111"); exists {
+		t.Errorf("Other options should not share the cache")
+	}
+}
+
+func TestBlameCacheRevert(t *testing.T) {
+	repo := newTestRepo(t, []testCommit{
+		{Author: "Alice", Date: "2016-01-01T12:00:00", Files: map[string]string{"f.c": "a\nb\n"}},
+		{Author: "Bob", Date: "2016-02-01T12:00:00", Files: map[string]string{"f.c": "c\nd\n"}},
+		{Author: "Carol", Date: "2016-03-01T12:00:00", Files: map[string]string{"f.c": "a\nb\n"}},
+	})
+	owners := func(at string) BlameCounts {
+		options := Options{Revisions: Revisions{At: at}, Jobs: 1}
+		cache, err := OpenBlameCache(repo, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := BlameFiles(context.Background(), repo, options, func(string) bool { return true }, cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cache.Save()
+		return result.Counts
+	}
+	owners("HEAD~2")
+	for author := range owners("HEAD") {
+		if author.Name != "Carol" {
+			t.Errorf("The lines of a reverted file should belong to whom reverted it, got %v", author)
+		}
+	}
+}
+
+func TestLastCommitsOddNames(t *testing.T) {
+	odd := "say \"hi\"\\\tnow.c"
+	repo := newTestRepo(t, []testCommit{
+		{Author: "Alice", Date: "2016-01-01T12:00:00", Files: map[string]string{odd: "a\n", "plain.c": "a\n"}},
+		{Author: "Bob", Date: "2016-02-01T12:00:00", Files: map[string]string{"plain.c": "b\n"}},
+		{Author: "Carol", Date: "2016-03-01T12:00:00"},
+	})
+	last, err := LastCommits(context.Background(), repo, Revisions{}, "HEAD", []string{odd, "plain.c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := runGit(repo, "rev-parse", "HEAD~2")
+	second, _ := runGit(repo, "rev-parse", "HEAD~1")
+	if last[odd] != strings.TrimSpace(string(first)) || last["plain.c"] != strings.TrimSpace(string(second)) {
+		t.Errorf("Unexpected last commits %v", last)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import "strings"
+
+// DiffOptions tell how each stage compares the lines, so that reindenting
+// or moving code doesn't change whom it is credited to
+type DiffOptions struct {
+	HistoryWhitespace bool // git log -w
+	BlameWhitespace   bool // git blame -w
+	BlameMoves        bool // git blame -M -C
+	MergeDiffs        bool // git log --diff-merges=first-parent
+}
+
+// HistoryArgs are the options of git log, historyOptions with the line
+// comparison
+func (d DiffOptions) HistoryArgs() []string {
+	args := append([]string(nil), historyOptions...)
+	if d.HistoryWhitespace {
+		args = append(args, "-w")
+	}
+	if d.MergeDiffs {
+		args = append(args, "--diff-merges=first-parent")
+	}
+	return args
+}
+
+func (d DiffOptions) BlameArgs() []string {
+	var args []string
+	if d.BlameWhitespace {
+		args = append(args, "-w")
+	}
+	if d.BlameMoves {
+		args = append(args, "-M", "-C")
+	}
+	return args
+}
+
+// String describes the settings in the report
+func (d DiffOptions) String() string {
+	describe := func(args []string) string {
+		if len(args) == 0 {
+			return "default"
+		}
+		return strings.Join(args, " ")
+	}
+	history := d.HistoryArgs()[len(historyOptions):]
+	return "history: " + describe(history) + ", blame: " + describe(d.BlameArgs())
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffOptions(t *testing.T) {
+	var diff DiffOptions
+	if !reflect.DeepEqual(diff.HistoryArgs(), historyOptions) || len(diff.BlameArgs()) != 0 {
+		t.Errorf("The default options should not change git's comparison")
+	}
+	if diff.String() != "history: default, blame: default" {
+		t.Errorf("Unexpected description %v", diff)
+	}
+
+	diff = DiffOptions{HistoryWhitespace: true, BlameMoves: true}
+	if args := diff.HistoryArgs(); args[len(args)-1] != "-w" || len(historyOptions) != len(args)-1 {
+		t.Errorf("Unexpected log arguments %v", args)
+	}
+	if args := diff.BlameArgs(); !reflect.DeepEqual(args, []string{"-M", "-C"}) {
+		t.Errorf("Unexpected blame arguments %v", args)
+	}
+	if diff.String() != "history: -w, blame: -M -C" {
+		t.Errorf("Unexpected description %v", diff)
+	}
+}
+
+func TestIgnoreWhitespace(t *testing.T) {
+	old := splitLines([]byte("int a;\nint b;\n"))
+	reindented := splitLines([]byte("  int a;\n\tint  b;\nint c;\n"))
+	if additions, deletions := countLineChanges(stripWhitespace(old), stripWhitespace(reindented)); additions != 1 || deletions != 0 {
+		t.Errorf("Only the new line should count, got +%v -%v", additions, deletions)
+	}
+	if additions, deletions := countLineChanges(stripWhitespace(old), stripWhitespace(splitLines([]byte("int a;\n\nint b;\n")))); additions != 1 || deletions != 0 {
+		t.Errorf("A blank line is not whitespace within a line, got +%v -%v", additions, deletions)
+	}
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -9,13 +11,15 @@ import (
 	"github.com/RodolpheFouquet/termtables"
 	"github.com/kardianos/osext"
 	"github.com/ttacon/chalk"
+	"io"
 	"io/ioutil"
 	"math"
 	"os"
 	"os/exec"
-	"path/filepath"
+	"os/signal"
+	"path"
+	"runtime"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -23,10 +27,26 @@ import (
 type Contribution struct {
 	Additions       int
 	Deletions       int
-	Commits         int
+	Commits         float64 // shared commits are split among their authors
+	Committed          int // commits applied by the contributor, in -attribution=both
+	CommittedAdditions int
+	CommittedDeletions int
+	GeneratedAdditions int // to the generated and vendored files, in -generated=separate
+	GeneratedDeletions int
+	GeneratedOwnedLines int // lines of the generated files at the tip, in -generated=separate
+	BinaryFiles        int // binary files changed and the bytes written
+	BinaryBytes        int
+	Integrations       int // merges, in -merges=integrations
+	Unmerged           map[string]int // commits by branch, of the branches not merged in the tip
+	Languages          map[string]*LanguageStats // the changes by language
+	Survival           *Survival // the lines written and still alive by day
+	OwnedLines         int // lines of the files at the tip, from the blame
+	SelectedOwnedLines int // the same in the selected files
 	CommitScore     float64
 	AdditionScore   float64
 	DifferenceScore float64
+	OwnedScore         float64
+	SelectedOwnedScore float64
 	Name            string
 	StartDate       time.Time
 	EndDate         time.Time	
@@ -95,8 +115,11 @@ func NewContributionDate(name string, period PeriodTS) *Contribution {
 
 // Json Users
 
+// A user matches the commits on its alias, on its email, or on both when
+// both are given
 type User struct {
 	Alias string `json:"alias"`
+	Email string `json:"email"`
 	Name  string `json:"name"`
 }
 
@@ -151,18 +174,53 @@ func (c *Contribution) SetScores(difference, addition, commits float64) {
 	c.CommitScore = commits
 }
 
+// SetOwnedScores sets the shares of the lines owned, which are shown apart
+// from the score of the history
+func (c *Contribution) SetOwnedScores(owned, selectedOwned float64) {
+	c.OwnedScore = owned
+	c.SelectedOwnedScore = selectedOwned
+}
+
+// Subtotal keeps the counters of one of the repositories merged in a Report
+type Subtotal struct {
+	Repo      string
+	Additions int
+	Deletions int
+	Commits   int
+}
+
 type Report struct {
 	Contributors   map[string]*Contributor
 	TotalAdditions int
 	TotalDeletions int
 	TotalCommits   int
+	TotalCommitted int
+	TotalCommittedAdditions int
+	TotalGenerated int
+	TotalGeneratedOwnedLines int
+	TotalOwnedLines int
+	TotalSelectedOwnedLines int
+	TotalBinaryFiles int
+	TotalBinaryBytes int
+	TotalIntegrations int
+	Languages      map[string]*LanguageStats
+	Outliers       []Outlier
 	TotalScore     float64
+	Subtotals      []*Subtotal
+	current        *Subtotal
 }
 
 func NewReport() *Report {
 	return &Report{Contributors: make(map[string]*Contributor), TotalAdditions: 0, TotalDeletions: 0, TotalCommits: 0, TotalScore: 0.0}
 }
 
+// StartRepository makes the following counters go to the subtotal of repo
+// as well as to the totals
+func (r *Report) StartRepository(repo string) {
+	r.current = &Subtotal{Repo: repo}
+	r.Subtotals = append(r.Subtotals, r.current)
+}
+
 func (r *Report) HasContributor(name string) bool {
 	_, exists := r.Contributors[name]
 	return exists
@@ -190,188 +248,624 @@ func (r *Report) AddContributor(name string, periodMap map[string][]PeriodTS) {
 }
 
 func (r *Report) IncrementCounters(name string, additions, deletions int, date time.Time) error {
-	if !r.HasContributor(name) {
-		fmt.Println("This contributor does not exist: ", r.Contributors[name] )
-		return errors.New("This contributor does not exist")
+	return r.IncrementSharedCounters([]string{name}, additions, deletions, true, date)
+}
+
+// IncrementSharedCounters credits lines written by several people, divided
+// among them when split is set or credited in full to each one otherwise.
+// The totals count the lines once.
+func (r *Report) IncrementSharedCounters(names []string, additions, deletions int, split bool, date time.Time) error {
+	for _, name := range names {
+		if !r.HasContributor(name) {
+			fmt.Println("This contributor does not exist: ", r.Contributors[name] )
+			return errors.New("This contributor does not exist")
+		}
+	}
+	for index, name := range names {
+		contribAdditions, contribDeletions := additions, deletions
+		if split {
+			contribAdditions = splitShare(additions, len(names), index)
+			contribDeletions = splitShare(deletions, len(names), index)
+		}
+		contrib := GetContribution(r.Contributors[name].Contributions, date)
+		contrib.IncrementCounters(contribAdditions, contribDeletions)
 	}
-	contrib := GetContribution(r.Contributors[name].Contributions, date)
-	contrib.IncrementCounters(additions, deletions)
 	r.TotalAdditions += additions
 	r.TotalDeletions += deletions
+	if r.current != nil {
+		r.current.Additions += additions
+		r.current.Deletions += deletions
+	}
 	return nil
 }
 
 func (r *Report) IncrementCommits(name string, date time.Time) error {
+	return r.IncrementSharedCommit([]string{name}, true, date)
+}
+
+// splitShare is the part of a count going to the index-th of count people,
+// the remainder going to the first ones, the author of the commit
+func splitShare(total, count, index int) int {
+	share := total / count
+	if index < total%count {
+		share++
+	}
+	return share
+}
+
+// IncrementGenerated credits the changes to generated and vendored files,
+// kept apart from the other lines
+func (r *Report) IncrementGenerated(names []string, additions, deletions int, split bool, date time.Time) error {
+	for _, name := range names {
+		if !r.HasContributor(name) {
+			fmt.Println("This contributor does not exist: ", r.Contributors[name] )
+			return errors.New("This contributor does not exist")
+		}
+	}
+	for index, name := range names {
+		contribAdditions, contribDeletions := additions, deletions
+		if split {
+			contribAdditions = splitShare(additions, len(names), index)
+			contribDeletions = splitShare(deletions, len(names), index)
+		}
+		contrib := GetContribution(r.Contributors[name].Contributions, date)
+		contrib.GeneratedAdditions += contribAdditions
+		contrib.GeneratedDeletions += contribDeletions
+	}
+	r.TotalGenerated += additions
+	return nil
+}
+
+// IncrementOwned credits the lines someone owns at the tip, in all the files
+// or in the selected ones
+func (r *Report) IncrementOwned(name string, lines int, selected bool, date time.Time) {
+	if !r.HasContributor(name) {
+		return
+	}
+	contrib := GetContribution(r.Contributors[name].Contributions, date)
+	if selected {
+		contrib.SelectedOwnedLines += lines
+		r.TotalSelectedOwnedLines += lines
+	} else {
+		contrib.OwnedLines += lines
+		r.TotalOwnedLines += lines
+	}
+}
+
+// IncrementGeneratedOwned credits the lines someone owns in the generated
+// files at the tip
+func (r *Report) IncrementGeneratedOwned(name string, lines int, date time.Time) {
+	if !r.HasContributor(name) {
+		return
+	}
+	contrib := GetContribution(r.Contributors[name].Contributions, date)
+	contrib.GeneratedOwnedLines += lines
+	r.TotalGeneratedOwnedLines += lines
+}
+
+// IncrementSharedCommit credits a commit made by several people, split among
+// them or counted for each one. The totals count the commit once.
+func (r *Report) IncrementSharedCommit(names []string, split bool, date time.Time) error {
+	for _, name := range names {
+		if !r.HasContributor(name) {
+			fmt.Println("This contributor does not exist: ", r.Contributors[name] )
+			return errors.New("This contributor does not exist")
+		}
+	}
+	share := 1.0
+	if split {
+		share = 1.0 / float64(len(names))
+	}
+	for _, name := range names {
+		contrib := GetContribution(r.Contributors[name].Contributions, date)
+		contrib.Commits += share
+	}
+	r.TotalCommits++
+	if r.current != nil {
+		r.current.Commits++
+	}
+	return nil
+}
+
+// IncrementCommitted credits the committer of changes, apart from their
+// authorship. commit is set for the first changes of a commit.
+func (r *Report) IncrementCommitted(name string, additions, deletions int, commit bool, date time.Time) error {
 	if !r.HasContributor(name) {
 		fmt.Println("This contributor does not exist: ", r.Contributors[name] )
 		return errors.New("This contributor does not exist")
 	}
 	contrib := GetContribution(r.Contributors[name].Contributions, date)
-	contrib.Commits++
-	r.TotalCommits++
+	contrib.CommittedAdditions += additions
+	contrib.CommittedDeletions += deletions
+	r.TotalCommittedAdditions += additions
+	if commit {
+		contrib.Committed++
+		r.TotalCommitted++
+	}
 	return nil
 }
 
-func ExecGitHistory(repo string) (string, error) {
-	command := exec.Command("git", "-C", repo, "log", "--numstat", "--pretty='%an|%ad'")
-	fmt.Println("Gathering the stats in the repo (1/3)", repo)
-	out, err := command.CombinedOutput()
+// commandReader streams the standard output of a command, Close waits for
+// the command and reports its failure
+type commandReader struct {
+	io.ReadCloser
+	command *exec.Cmd
+	stderr  *bytes.Buffer
+}
+
+func startCommand(command *exec.Cmd) (io.ReadCloser, error) {
+	// keep the warnings git prints on stderr out of the parsed output
+	stderr := &bytes.Buffer{}
+	command.Stderr = stderr
+	stdout, err := command.StdoutPipe()
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if err := command.Start(); err != nil {
+		return nil, err
 	}
-	return string(out), nil
+	return &commandReader{ReadCloser: stdout, command: command, stderr: stderr}, nil
 }
 
-func parseGitOutputHistory(gitOutput string, report *Report, subtree string, periodMap map[string][]PeriodTS, userMap map[string]string) {
-	reader := bufio.NewReader(strings.NewReader(gitOutput))
-	currentContributor := ""
-	var timeString string
-	hasContributed := false
-	for {
-		line, _, err := reader.ReadLine()
-		if err != nil {
-			break
-		}
-		lineString := string(line)
-		if len(string(line)) == 0 {
-			continue
+func (c *commandReader) Close() error {
+	c.ReadCloser.Close()
+	message := strings.TrimSpace(c.stderr.String())
+	if err := c.command.Wait(); err != nil {
+		return fmt.Errorf("%v: %v", err, message)
+	}
+	if message != "" {
+		fmt.Println(chalk.Yellow, c.command.Args[3], ": ", message)
+	}
+	return nil
+}
+
+func ExecGitHistory(repo string, revs Revisions, diff DiffOptions) (io.ReadCloser, error) {
+	args := append(append([]string{"-C", repo, "log"}, diff.HistoryArgs()...), revs.LogArgs()...)
+	command := exec.Command("git", append(args, "--")...)
+	fmt.Println("Gathering the stats in the repo (1/3)", repo)
+	return startCommand(command)
+}
+
+// ExecCachedGitHistory runs git log on the selected commits missing from the
+// cache only, the history is then read from the cache. The commits are
+// streamed from rev-list on both passes, so that none is held in memory.
+func ExecCachedGitHistory(repo string, revs Revisions, diff DiffOptions, cache *HistoryCache) (io.ReadCloser, error) {
+	fmt.Println("Gathering the stats in the repo (1/3)", repo)
+	if err := fillHistoryCache(repo, revs, diff, cache); err != nil {
+		return nil, err
+	}
+	hashes, err := startCommand(revListCommand(repo, revs))
+	if err != nil {
+		return nil, err
+	}
+	reader, writer := io.Pipe()
+	go func() {
+		err := cache.Replay(hashes, writer)
+		if closeErr := hashes.Close(); err == nil {
+			err = closeErr
 		}
+		writer.CloseWithError(err)
+	}()
+	return reader, nil
+}
 
-		splittedLine := strings.Split(lineString, "\t")
+func revListCommand(repo string, revs Revisions) *exec.Cmd {
+	return exec.Command("git", append(append([]string{"-C", repo, "rev-list"}, revs.LogArgs()...), "--")...)
+}
 
-		if len(splittedLine) == 1 {
-			contribAndDate := strings.Split(lineString, "|")
-			alias := strings.Replace(contribAndDate[0], "'", "", -1)
-			_, exists := userMap[alias]
-			if exists {
-				currentContributor = userMap[alias]
-				if (currentContributor == "") {
-					fmt.Println(chalk.Yellow, "Skip user: ", alias)
-					continue
-				}
-			} else {
-				currentContributor = alias
-			}
-			timeString = strings.Replace(contribAndDate[1], "'", "", -1)
-			hasContributed = false
-		} else if len(splittedLine) == 3 {
-			pathModified := fmt.Sprintf("/%s", splittedLine[2])
-			rel, err := filepath.Rel(subtree, pathModified)
-			if err != nil {
-				fmt.Println(chalk.Yellow, "Relative Warning: ", err)
+// fillHistoryCache passes the commits missing from the cache to git log as
+// rev-list finds them. git log reads its whole standard input before
+// writing anything, so its output is only read once rev-list is done.
+func fillHistoryCache(repo string, revs Revisions, diff DiffOptions, cache *HistoryCache) error {
+	hashes, err := startCommand(revListCommand(repo, revs))
+	if err != nil {
+		return err
+	}
+	var stdin *bufio.Writer
+	var closeStdin io.Closer
+	var gitOutput io.ReadCloser
+	total, missing := 0, 0
+	scanner := bufio.NewScanner(hashes)
+	for scanner.Scan() {
+		total++
+		if cache.Has(scanner.Text()) {
+			continue
+		}
+		if gitOutput == nil {
+			args := append(append([]string{"-C", repo, "log", "--no-walk=unsorted"}, diff.HistoryArgs()...), "--stdin")
+			command := exec.Command("git", args...)
+			var pipe io.WriteCloser
+			if pipe, err = command.StdinPipe(); err != nil {
+				break
 			}
-			if strings.Contains(rel, "..") {
-				continue
+			if gitOutput, err = startCommand(command); err != nil {
+				break
 			}
+			stdin, closeStdin = bufio.NewWriter(pipe), pipe
+		}
+		missing++
+		if _, err = fmt.Fprintln(stdin, scanner.Text()); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = scanner.Err()
+	}
+	if closeErr := hashes.Close(); err == nil {
+		err = closeErr
+	}
+	if gitOutput == nil {
+		return err
+	}
+	if flushErr := stdin.Flush(); err == nil {
+		err = flushErr
+	}
+	closeStdin.Close()
+	if err == nil {
+		fmt.Println("Reading", missing, "new commits out of", total)
+		err = cache.Fill(gitOutput)
+	}
+	if closeErr := gitOutput.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
 
-			additions, err := strconv.Atoi(splittedLine[0])
-			if err != nil {
-				additions = 0
-			}
-			deletions, err := strconv.Atoi(splittedLine[1])
-			if err != nil {
-				deletions = 0
-			}
-			
-			date,_ := time.Parse("Mon Jan 2 15:04:05 2006 -0700", timeString)
-				
-			if !hasContributed {
-				hasContributed = true
-				report.AddContributor(currentContributor, periodMap)
-				report.IncrementCommits(currentContributor, date)
-			}
-			report.IncrementCounters(currentContributor, additions, deletions, date)
-		} else {
-			fmt.Println(chalk.Yellow, "Error: unprocessed line (history): ", lineString)
+func HasGit() bool {
+	_, err := exec.LookPath("git")
+	return err == nil
+}
+
+const historyProgressInterval = 10000 // commits
+
+// commitCredit is whom a commit is credited to and when
+type commitCredit struct {
+	contributors    []string
+	split           bool
+	date            time.Time
+	committer       string
+	creditCommitter bool
+	commitDate      time.Time
+}
+
+// creditFile credits the changes to a file in a report, the commit being
+// counted with the first file it has there
+func (c commitCredit) creditFile(report *Report, file FileStat, first bool, periodMap map[string][]PeriodTS) {
+	if c.creditCommitter {
+		report.AddContributor(c.committer, periodMap)
+		report.IncrementCommitted(c.committer, file.Additions, file.Deletions, first, c.commitDate)
+	}
+	if first {
+		for _, contributor := range c.contributors {
+			report.AddContributor(contributor, periodMap)
 		}
+		report.IncrementSharedCommit(c.contributors, c.split, c.date)
 	}
+	report.IncrementSharedCounters(c.contributors, file.Additions, file.Deletions, c.split, c.date)
+	report.IncrementLanguage(c.contributors, Language(file.Path), file.Additions, file.Deletions, c.split, c.date)
+	report.IncrementWritten(c.contributors, file.Additions, c.split, c.date)
 }
 
-func parseGitOutputBlame(gitOutput string, report *Report, userMap map[string]string) {
-	reader := bufio.NewReader(strings.NewReader(gitOutput))
-	currentContributor := ""
-	var timeString string
+func (c commitCredit) creditGenerated(report *Report, file FileStat, periodMap map[string][]PeriodTS) {
+	for _, contributor := range c.contributors {
+		report.AddContributor(contributor, periodMap)
+	}
+	report.IncrementGenerated(c.contributors, file.Additions, file.Deletions, c.split, c.date)
+}
+
+func parseGitOutputHistory(gitOutput io.Reader, parser *Parser) error {
+	return parser.scanHistory(gitOutput, true, func(commit *CommitStats, contributors []string, files []commitFile) {
+		if len(commit.Parents) > 1 && parser.Merges == MergesIntegrations {
+			parser.creditIntegration(commit, contributors, files)
+		} else {
+			parser.creditOutlierCommit(commit, contributors, files)
+		}
+	})
+}
+
+// scanHistory passes each commit of the history to credit with its
+// contributors and files, leaving out the ignored commits and merges and
+// those without anyone to credit
+func (p *Parser) scanHistory(gitOutput io.Reader, progress bool, credit func(commit *CommitStats, contributors []string, files []commitFile)) error {
+	scanner := NewHistoryScanner(gitOutput)
 	for {
-		line, _, err := reader.ReadLine()
-		if err != nil {
+		commit, err := scanner.Scan()
+		if err == io.EOF {
 			break
 		}
-		lineString := string(line)
-		if len(string(line)) == 0 {
+		if err != nil {
+			return err
+		}
+		if progress && scanner.Commits%historyProgressInterval == 0 {
+			fmt.Println("Parsed", scanner.Commits, "commits")
+		}
+
+		if p.ignored[commit.Hash] {
+			// the moves of an ignored commit still apply to the later ones
+			for _, file := range commit.Files {
+				if p.renames != nil && file.OldPath != "" {
+					p.renames.Rename(file.OldPath, file.Path)
+				}
+			}
+			continue
+		}
+		contributors := p.commitContributors(commit)
+		if len(contributors) == 0 {
+			continue
+		}
+		merge := len(commit.Parents) > 1
+		// git log --first-parent diffs the merges even when they are ignored
+		if merge && p.Merges == MergesIgnore {
 			continue
 		}
+		credit(commit, contributors, p.commitFiles(commit))
+	}
+	if progress {
+		fmt.Println("Parsed", scanner.Commits, "commits")
+	}
+	return nil
+}
 
-		splittedLine := strings.Split(strings.Trim(lineString, " "), " ")
+// commitFile is a file of a commit with the reports it is credited to
+type commitFile struct {
+	FileStat
+	reports   []*Report
+	generated bool
+}
 
-		if len(splittedLine) >= 3 {
-			alias := strings.Join(splittedLine[2:], " ")
-			_, exists := userMap[alias]
-			if exists {
-				currentContributor = userMap[alias]
-				if (currentContributor == "") {
-					fmt.Println(chalk.Yellow, "Skip user: ", alias)
-					continue
-				}
-			} else {
-				currentContributor = alias
+// commitFiles returns the files of a commit which are credited
+func (p *Parser) commitFiles(commit *CommitStats) []commitFile {
+	var files []commitFile
+	for _, file := range commit.Files {
+		// the subtree applies to the destination of renames
+		location := file.Path
+		if p.renames != nil {
+			location = p.renames.Resolve(file.Path)
+			if file.OldPath != "" {
+				p.renames.Rename(file.OldPath, file.Path)
 			}
+		}
+		pathModified := fmt.Sprintf("/%s", path.Join(p.Prefix, location))
+		// the history of the submodule replaces the updates of its commit
+		if p.gitlinks[pathModified] {
+			continue
+		}
+		reports := p.reportsFor(pathModified)
+		if len(reports) == 0 || !p.Paths.Match(pathModified) {
+			continue
+		}
 
-			additions, err := strconv.Atoi(splittedLine[0])
-			if err != nil {
-				fmt.Println(chalk.Yellow, "Skip blame contribution: ", lineString)
-				additions = 0
+		// -diff is what makes many files binary, only the generated and
+		// vendored ones are left out
+		if file.Binary {
+			if p.Generated == GeneratedCount || !p.attributes.Vendored(location) {
+				file.Size = p.binarySize(file)
+				files = append(files, commitFile{FileStat: file, reports: reports})
 			}
+			continue
+		}
 
-			//increment as additions
-			date,_ := time.Parse("Mon Jan 2 15:04:05 2023 -0700", timeString)
-			factor := 1
-			report.IncrementCounters(currentContributor, additions * factor, 0, date)
-		} else {
-			fmt.Println(chalk.Yellow, "Error: unprocessed line (blame): ", len(splittedLine), lineString)
+		generated := p.Generated != GeneratedCount && p.attributes.Generated(location)
+		if generated && p.Generated == GeneratedSkip {
+			continue
 		}
+		files = append(files, commitFile{FileStat: file, reports: reports, generated: generated})
 	}
+	return files
 }
 
-func ExecGitBlameRaw(repo string) (string, error) {
-	cmdGit := "git ls-tree -r -z --name-only HEAD -- | grep -z -Z -v extra_lib | sed 's/^/.\\//' | xargs -0 -n1 git blame --line-porcelain HEAD |grep -ae \"^author \"|sort|uniq -c|sort -nr"
-	command := exec.Command("bash", "-c", cmdGit)
-	command.Dir = repo
-	out, err := command.CombinedOutput()
-	if err != nil {
-		return "", err
+func (p *Parser) creditOf(commit *CommitStats, contributors []string) commitCredit {
+	credit := commitCredit{contributors: contributors, split: p.CoAuthors != CoAuthorsDuplicate, date: commit.Date, commitDate: commit.CommitDate}
+	// the periods apply to the date of whom is credited
+	if p.Attribution == AttributionCommitter {
+		credit.date = commit.CommitDate
+	}
+	if p.Attribution == AttributionBoth {
+		credit.committer, credit.creditCommitter = p.identities.Resolve(Identity{Name: commit.Committer, Email: commit.CommitterEmail})
 	}
-	return string(out), nil
+	return credit
 }
 
-func ExecGitBlameSelected(repo string) (string, error) {
-	cmdGit := "git ls-tree --name-only -z -r HEAD|egrep -z -Z -E 'configure|Makefile|\\.(h|cpp|c|js)$'|grep -z -Z -v extra_lib|xargs -0 -n1 git blame --line-porcelain|grep \"^author \"|sort|uniq -c|sort -nr"
-	command := exec.Command("bash", "-c", cmdGit)
-	command.Dir = repo
-	fmt.Println("Gathering the stats in the repo (3/3)", repo)
-	out, err := command.CombinedOutput()
-	if err != nil {
-		return "", err
+// creditCommit credits the files of a commit to its contributors, their
+// lines scaled down to limit when the commit is larger
+func (p *Parser) creditCommit(commit *CommitStats, contributors []string, files []commitFile, limit int) {
+	credit := p.creditOf(commit, contributors)
+	size := commitSize(files)
+
+	counted := make(map[*Report]bool)
+	for _, file := range files {
+		stat := file.FileStat
+		if limit > 0 && size > limit && !file.generated {
+			stat.Additions = stat.Additions * limit / size
+			stat.Deletions = stat.Deletions * limit / size
+		}
+		for _, report := range file.reports {
+			if file.Binary {
+				credit.creditBinary(report, stat, p.BinaryCommits && !counted[report], p.periodMap)
+				counted[report] = counted[report] || p.BinaryCommits
+			} else if file.generated {
+				credit.creditGenerated(report, stat, p.periodMap)
+			} else {
+				credit.creditFile(report, stat, !counted[report], p.periodMap)
+				counted[report] = true
+			}
+		}
 	}
-	return string(out), nil
+	if branches := p.unmerged[commit.Hash]; len(branches) > 0 {
+		for report := range counted {
+			report.IncrementUnmerged(contributors, branches, credit.date)
+		}
+	}
+}
+
+// commitContributors returns the people credited for a commit: its author,
+// then its co-authors unless they are ignored, all going through the
+// identities. In -attribution=committer, it is the committer alone.
+func (p *Parser) commitContributors(commit *CommitStats) []string {
+	var contributors []string
+	seen := make(map[string]bool)
+	identities := []Identity{{Name: commit.Author, Email: commit.Email}}
+	if p.Attribution == AttributionCommitter {
+		identities = []Identity{{Name: commit.Committer, Email: commit.CommitterEmail}}
+	} else if p.CoAuthors != CoAuthorsIgnore {
+		for _, coAuthor := range commit.CoAuthors {
+			identities = append(identities, Identity{Name: coAuthor.Name, Email: coAuthor.Email})
+		}
+	}
+	for _, identity := range identities {
+		contributor, credited := p.identities.Resolve(identity)
+		if credited && !seen[contributor] {
+			seen[contributor] = true
+			contributors = append(contributors, contributor)
+		}
+	}
+	return contributors
+}
+
+// addBlameCounts credits each author with the lines they own, in all the
+// files or in the selected ones, in the period they wrote them. The owners
+// without any commit credited by the history are added too.
+func addBlameCounts(counts BlameCounts, selected bool, report *Report, identities *Identities, periodMap map[string][]PeriodTS) {
+	creditBlameCounts(counts, identities, func(contributor string, lines int, date time.Time) {
+		report.AddContributor(contributor, periodMap)
+		report.IncrementOwned(contributor, lines, selected, date)
+	})
+}
+
+// addGeneratedBlameCounts credits each author with the generated lines they
+// own, apart from the generated additions of the history
+func addGeneratedBlameCounts(counts BlameCounts, report *Report, identities *Identities, periodMap map[string][]PeriodTS) {
+	creditBlameCounts(counts, identities, func(contributor string, lines int, date time.Time) {
+		report.AddContributor(contributor, periodMap)
+		report.IncrementGeneratedOwned(contributor, lines, date)
+	})
+}
+
+func creditBlameCounts(counts BlameCounts, identities *Identities, credit func(contributor string, lines int, date time.Time)) {
+	authors := make([]BlameLine, 0, len(counts))
+	for author := range counts {
+		authors = append(authors, author)
+	}
+	sort.Slice(authors, func(i, j int) bool {
+		if authors[i].Identity != authors[j].Identity {
+			return authors[i].String() < authors[j].String()
+		}
+		return authors[i].Time < authors[j].Time
+	})
+	for _, author := range authors {
+		currentContributor, credited := identities.Resolve(author.Identity)
+		if !credited {
+			continue
+		}
+		credit(currentContributor, counts[author], author.Date())
+	}
+}
+
+// How the commits with Co-authored-by trailers are credited
+const (
+	CoAuthorsSplit     = "split"
+	CoAuthorsDuplicate = "duplicate"
+	CoAuthorsIgnore    = "ignore"
+)
+
+// Whom the history credits: the author, the committer, or the author with
+// the committer in separate columns
+const (
+	AttributionAuthor    = "author"
+	AttributionCommitter = "committer"
+	AttributionBoth      = "both"
+)
+
+// Parser accumulates the output of the git stages into a Report
+type Parser struct {
+	Report      *Report // overall, the union of the sections
+	Sections    []*Section
+	CoAuthors   string
+	Attribution string
+	Paths       PathFilter
+	Generated   string
+	Prefix      string // path of the submodule being parsed
+	gitlinks    map[string]bool // submodules whose own history is parsed
+	periodMap  map[string][]PeriodTS
+	identities *Identities
+	attributes *Attributes
+	Outliers    OutlierPolicy
+	BinaryCommits bool // the commits of binary files alone are counted
+	Merges      string
+	blobSizes  BlobSizer
+	ignored    map[string]bool // hashes of the commits left out
+	unmerged   map[string][]string // branches of the commits the tip doesn't have
+	renames    *RenameTracker
+	outlierThreshold int // of the repository being parsed, from MeasureHistory
 }
 
-func ParseStats(gitOutput1 string, gitOutput2 string, gitOutput3 string, subtree string, periods PeriodArray, users UserArray) (*Report, error) {
+func NewParser(subtree string, periods PeriodArray, users UserArray) *Parser {
 	periodMap := make(map[string][]PeriodTS)
 	for _, period := range periods.Periods {
 		periodMap[period.User] = append(periodMap[period.User], *NewPeriodTS(period))
 	}
-	userMap := make(map[string]string)
-	for _, user := range users.Users {
-		userMap[user.Alias] = user.Name
+	parser := &Parser{Report: NewReport(), CoAuthors: CoAuthorsSplit, Attribution: AttributionAuthor, Generated: GeneratedSkip, Outliers: OutlierPolicy{Action: OutliersNone}, Merges: MergesIgnore, periodMap: periodMap, identities: NewIdentities(users)}
+	parser.SetModules(SubtreeModules(subtree))
+	return parser
+}
+
+// FollowRenames credits the changes made to a file before it was moved to
+// the subtree where it lives now, and not those made before it left it
+func (p *Parser) FollowRenames() {
+	p.renames = NewRenameTracker()
+}
+
+// SetMailmap applies the .mailmap of the repository being parsed
+func (p *Parser) SetMailmap(mailmap *Mailmap) {
+	p.identities.SetMailmap(mailmap)
+}
+
+// SetAttributes applies the .gitattributes of the repository being parsed
+func (p *Parser) SetAttributes(attributes *Attributes) {
+	p.attributes = attributes
+}
+
+// SetBlobSizer gives the size of the binary files of the repository being
+// parsed, nil leaving it unknown
+func (p *Parser) SetBlobSizer(sizes BlobSizer) {
+	p.blobSizes = sizes
+}
+
+// SetIgnoredCommits leaves out the commits of the repository being parsed,
+// given by their full hash
+func (p *Parser) SetIgnoredCommits(hashes []string) {
+	p.ignored = make(map[string]bool)
+	for _, hash := range hashes {
+		p.ignored[hash] = true
+	}
+}
+
+func (p *Parser) ParseHistory(gitOutput io.Reader) error {
+	fmt.Println("Parsing the stats from the repo using ", p.subtrees(), " as subtree")
+	if p.renames != nil {
+		// each repository has its own paths
+		p.FollowRenames()
 	}
-	fmt.Println("Parsing the stats from the repo using ", subtree," as subtree" )
-	report := NewReport()
+	err := parseGitOutputHistory(gitOutput, p)
+	// each repository has its own outliers
+	p.outlierThreshold = 0
+	return err
+}
+
+func (p *Parser) AddBlame(counts BlameCounts, selected bool) {
+	addBlameCounts(counts, selected, p.Report, p.identities, p.periodMap)
+}
+
 
-	parseGitOutputHistory(gitOutput1, report, subtree, periodMap, userMap)
-	parseGitOutputBlame(gitOutput2, report, userMap)
-	parseGitOutputBlame(gitOutput3, report, userMap)
+func ParseStats(gitOutput1 io.Reader, blameRaw BlameCounts, blameSelected BlameCounts, subtree string, periods PeriodArray, users UserArray) (*Report, error) {
+	parser := NewParser(subtree, periods, users)
+	if err := parser.ParseHistory(gitOutput1); err != nil {
+		return nil, err
+	}
+	parser.AddBlame(blameRaw, false)
+	parser.AddBlame(blameSelected, true)
 
-	return report, nil
+	return parser.Report, nil
 }
 
 type OrderByScore []Contribution
@@ -397,6 +891,204 @@ func PrintHelp(success bool) {
 	}
 }
 
+// Options of a run, shared by all the analysed repositories
+type Options struct {
+	Revisions      Revisions
+	Diff           DiffOptions
+	Native         bool
+	Git            bool
+	Cache          bool
+	Submodules     bool
+	Prefix         string // path of the submodule being analysed
+	Paths          PathFilter
+	Generated      string
+	attributes     *Attributes // of the repository being analysed
+	IgnoreRevsFile string      // relative to each repository unless absolute
+	IgnoreCommits  []string
+	RefPatterns    []string // refs walked besides the tip
+	Jobs           int
+	Timings        int
+}
+
+// readHistory opens the history, passes it to parse and closes it
+func readHistory(open func() (io.ReadCloser, error), parse func(io.Reader) error) error {
+	history, err := open()
+	if err != nil {
+		return err
+	}
+	err = parse(history)
+	if closeErr := history.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// AnalyseRepository runs the history and blame stages on a repository and
+// merges their results into the report of the parser
+func AnalyseRepository(ctx context.Context, parser *Parser, repo string, options Options) error {
+	parser.StartRepository(repo)
+	return analyseRepository(ctx, parser, repo, options)
+}
+
+func analyseRepository(ctx context.Context, parser *Parser, repo string, options Options) error {
+	mailmap, err := ReadMailmap(repo)
+	if err != nil {
+		return err
+	}
+	parser.SetMailmap(mailmap)
+	var submodules []Submodule
+	if options.Submodules {
+		if submodules, err = ListSubmodules(repo, options.Revisions); err != nil {
+			return err
+		}
+	}
+	if options.Generated != GeneratedCount {
+		if options.attributes, err = ReadAttributes(repo, options.Revisions.Tip()); err != nil {
+			fmt.Println(chalk.Yellow, "Skip the .gitattributes: ", err)
+		}
+	}
+	parser.SetAttributes(options.attributes)
+	if options.Revisions.Ignored, err = ignoredCommits(repo, options); err != nil {
+		fmt.Println(chalk.Yellow, "Skip the ignored commits: ", err)
+	}
+	parser.SetIgnoredCommits(options.Revisions.Ignored)
+	if options.Revisions.Refs, err = ListRefs(repo, options.RefPatterns, options.Git); err != nil {
+		return err
+	}
+	unmerged, err := UnmergedCommits(repo, options.Revisions, options.Git)
+	if err != nil {
+		return err
+	}
+	parser.SetUnmerged(unmerged)
+	parser.SetBlobSizer(nil)
+	if options.Native {
+		if store, err := OpenObjectStore(repo); err == nil {
+			defer store.Close()
+			parser.SetBlobSizer(store)
+		}
+	} else if catFile, err := StartCatFile(repo); err == nil {
+		defer catFile.Close()
+		parser.SetBlobSizer(catFile)
+	} else {
+		fmt.Println(chalk.Yellow, "Skip the size of the binary files: ", err)
+	}
+	parser.gitlinks = make(map[string]bool)
+	for _, submodule := range submodules {
+		parser.gitlinks["/"+path.Join(options.Prefix, submodule.Path)] = true
+	}
+
+	var historyCache *HistoryCache
+	if options.Cache {
+		cacheOptions := options.Diff.HistoryArgs()
+		if options.Native {
+			// the built-in rename detection differs from git's
+			cacheOptions = append([]string{"native"}, cacheOptions...)
+		}
+		if historyCache, err = OpenHistoryCache(repo, cacheOptions); err != nil {
+			fmt.Println(chalk.Yellow, "Skip the history cache: ", err)
+		} else {
+			defer historyCache.Close()
+		}
+	}
+	openHistory := func() (io.ReadCloser, error) {
+		if options.Native {
+			return ReadGitHistory(repo, options.Revisions, options.Diff, historyCache)
+		} else if historyCache != nil {
+			return ExecCachedGitHistory(repo, options.Revisions, options.Diff, historyCache)
+		}
+		return ExecGitHistory(repo, options.Revisions, options.Diff)
+	}
+	parser.Prefix = options.Prefix
+	if parser.Outliers.Action != OutliersNone {
+		// the history is read twice rather than kept in memory until the
+		// sizes of all the commits are known
+		if err := readHistory(openHistory, parser.MeasureHistory); err != nil {
+			return err
+		}
+	}
+	if err := readHistory(openHistory, parser.ParseHistory); err != nil {
+		return err
+	}
+
+	if !options.Git {
+		fmt.Println(chalk.Yellow, "git was not found, skipping the blame stages (2/3, 3/3)")
+	} else if err := analyseBlame(ctx, parser, repo, options); err != nil {
+		return err
+	}
+	return analyseSubmodules(ctx, parser, repo, submodules, options)
+}
+
+func analyseBlame(ctx context.Context, parser *Parser, repo string, options Options) error {
+	if rev, err := options.Revisions.BlameRevision(repo); err != nil {
+		return err
+	} else if rev == "" {
+		fmt.Println(chalk.Yellow, "No commit before ", options.Revisions.Until, ", skipping the blame stages (2/3, 3/3)")
+		return nil
+	}
+	var err error
+	if options.Revisions, err = options.Revisions.ResolveSince(repo); err != nil {
+		return err
+	}
+	var blameCache *BlameCache
+	if options.Cache {
+		// the tip is left out of the options, the cache lasting across commits
+		// as the files are told apart by the last commit changing them
+		if blameCache, err = OpenBlameCache(repo, append(options.Diff.BlameArgs(), options.Revisions.BlameArgs("")...)); err != nil {
+			fmt.Println(chalk.Yellow, "Skip the blame cache: ", err)
+		}
+	}
+	blameRaw, err := ExecGitBlameRaw(ctx, repo, options, blameCache)
+	if err != nil {
+		return err
+	}
+	PrintTimings(blameRaw, options.Timings)
+	parser.AddBlameFiles(blameRaw.Files, false)
+	parser.AddSurvivingFiles(blameRaw.Files)
+
+	parser.AddBlameFiles(SelectBlame(repo, blameRaw, options).Files, true)
+
+	if options.Generated == GeneratedSeparate {
+		blameGenerated, err := ExecGitBlameGenerated(ctx, repo, options, blameCache)
+		if err != nil {
+			return err
+		}
+		PrintTimings(blameGenerated, options.Timings)
+		parser.AddGeneratedBlameFiles(blameGenerated.Files)
+	}
+	if err := blameCache.Save(); err != nil {
+		fmt.Println(chalk.Yellow, "Could not save the blame cache: ", err)
+	}
+	return nil
+}
+
+// analyseSubmodules folds the submodules of a repository into its report,
+// their paths prefixed with the one they have in the parent tree
+func analyseSubmodules(ctx context.Context, parser *Parser, repo string, submodules []Submodule, options Options) error {
+	for _, submodule := range submodules {
+		location, err := submodule.Locate(repo)
+		if err != nil {
+			fmt.Println(chalk.Yellow, "Skip submodule ", submodule.Path, ": ", err)
+			continue
+		}
+		submoduleOptions := submodule.Options(options)
+		fmt.Println("Entering the submodule", submoduleOptions.Prefix)
+		if err := analyseRepository(ctx, parser, location, submoduleOptions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func PrintTimings(result *BlameResult, count int) {
+	if count <= 0 || len(result.Timings) == 0 {
+		return
+	}
+	fmt.Println("Slowest files:")
+	for _, timing := range result.Slowest(count) {
+		fmt.Printf("%10v  %v\n", timing.Duration.Round(time.Millisecond), timing.Path)
+	}
+}
+
 func DecodeJson(jsonBlob []byte) (PeriodArray, UserArray, error) {
 	var periods PeriodArray
 	var users UserArray
@@ -412,18 +1104,62 @@ func DecodeJson(jsonBlob []byte) (PeriodArray, UserArray, error) {
 }
 
 func main() {
-	directory := flag.String("repo", "", "[mandatory] Path to the git repository")
-	subtree := flag.String("subtree", "/", "[optional] Subtree you want to parse")
+	var directories RepoList
+	flag.Var(&directories, "repo", "[mandatory] Path to the git repository, can be repeated")
+	manifest := flag.String("manifest", "", "[optional] File listing the repositories to analyse, one per line")
+	subtree := flag.String("subtree", "/", "[optional] Subtree you want to parse, or a comma separated list of them")
 	config := flag.String("config", "", "[optional] Path to the configuration file")
+	jobs := flag.Int("jobs", 0, "[optional] Number of files blamed in parallel, 0 for the number of CPUs")
+	timings := flag.Int("timings", 0, "[optional] Displays the N slowest files of each blame stage")
+	since := flag.String("since", "", "[optional] Only looks at the commits more recent than this date")
+	until := flag.String("until", "", "[optional] Only looks at the commits older than this date")
+	revRange := flag.String("rev-range", "", "[optional] Only looks at the commits of the range A..B")
+	at := flag.String("at", "", "[optional] Analyses the repository as of this ref instead of HEAD")
+	coAuthors := flag.String("coauthors", CoAuthorsSplit, "[optional] Credits the Co-authored-by trailers: split, duplicate or ignore")
+	attribution := flag.String("attribution", AttributionAuthor, "[optional] Credits the history to the author, the committer, or both in separate columns")
+	noCache := flag.Bool("no-cache", false, "[optional] Neither reads nor writes the cache kept in .git/git-stats")
+	submodules := flag.Bool("submodules", false, "[optional] Recurses into the submodules at the commits recorded by their parent")
+	generated := flag.String("generated", GeneratedSkip, "[optional] Counts the generated, vendored and -diff files of .gitattributes: skip, separate or count")
+	ignoreRevsFile := flag.String("ignore-revs-file", "", "[optional] File of the commits to leave out, like blame.ignoreRevsFile, relative to each repository")
+	outliers := flag.String("outliers", OutliersNone, "[optional] Handles the commits much larger than the others: none, flag, cap or exclude")
+	outlierDeviations := flag.Float64("outlier-deviations", 5, "[optional] Median absolute deviations of the logarithm of the commit sizes above which a commit is an outlier, 0 to disable, off when -outlier-lines is given alone")
+	outlierLines := flag.Int("outlier-lines", 0, "[optional] Number of lines above which a commit is an outlier, 0 to disable, the lower threshold applying when both are given")
+	historyWhitespace := flag.Bool("history-whitespace", false, "[optional] Ignores the whitespace when counting the lines of the history (git log -w)")
+	blameWhitespace := flag.Bool("blame-whitespace", false, "[optional] Ignores the whitespace when blaming the lines (git blame -w)")
+	blameMoves := flag.Bool("blame-moves", false, "[optional] Credits the lines moved or copied to their original author (git blame -M -C)")
+	merges := flag.String("merges", MergesIgnore, "[optional] Handles the merges: ignore, first-parent to credit their diff and follow the mainline, or integrations to count them apart")
+	firstParent := flag.Bool("first-parent", false, "[optional] Only looks at the mainline, following the first parent of merges")
+	allRefsFlag := flag.Bool("all-refs", false, "[optional] Also looks at the local and remote-tracking branches, each commit counted once")
+	binaryCommits := flag.Bool("binary-commits", false, "[optional] Counts the commits changing binary files alone")
+	languages := flag.Bool("languages", false, "[optional] Displays the additions and deletions of each language")
+	survival := flag.Bool("survival", false, "[optional] Displays how many of the lines of each contributor are still alive, their ages and half-life")
+	followRenames := flag.Bool("follow-renames", false, "[optional] Applies the subtree to the latest path of the moved files")
+	native := flag.Bool("native", false, "[optional] Reads the history with the built-in git reader instead of the git binary")
 	help := flag.Bool("help", false, "[optional] Displays this helps and quit")
+	var includes, excludes PatternList
+	flag.Var(&includes, "include", "[optional] Only looks at the files matching this pattern, can be repeated")
+	flag.Var(&excludes, "exclude", "[optional] Leaves out the files matching this pattern, can be repeated")
+	var refs PatternList
+	flag.Var(&refs, "refs", "[optional] Also looks at the refs matching this glob, like refs/heads/release/*, can be repeated")
 	periods := *NewPeriodArray()
 	users:= *NewUserArray()
+	var paths PathFilter
+	var modules []Module
+	var ignoreCommits []string
 
 	flag.Parse()
 	if *help {
 		PrintHelp(true)
 	}
-	if *directory == "" {
+	if *manifest != "" {
+		repos, err := ReadManifest(*manifest)
+		if err != nil {
+			fmt.Println(chalk.Red, "Error while reading the manifest ", err)
+			os.Exit(1)
+		}
+		directories = append(directories, repos...)
+	}
+	if len(directories) == 0 {
 		PrintHelp(false)
 	}
 	if *config != "" {
@@ -438,44 +1174,186 @@ func main() {
 			fmt.Println(chalk.Red, "Error while decoding the configuration file ", err)
 			os.Exit(1)
 		}
+		paths, err = DecodePathFilter(json)
+		if err != nil {
+			fmt.Println(chalk.Red, "Error while decoding the configuration file ", err)
+			os.Exit(1)
+		}
+		if modules, err = DecodeModules(json); err != nil {
+			fmt.Println(chalk.Red, "Error while decoding the configuration file ", err)
+			os.Exit(1)
+		}
+		if ignoreCommits, err = DecodeIgnoredCommits(json); err != nil {
+			fmt.Println(chalk.Red, "Error while decoding the configuration file ", err)
+			os.Exit(1)
+		}
+	}
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	// the modules of the configuration replace the default subtree
+	if given["subtree"] || len(modules) == 0 {
+		modules = append(SubtreeModules(*subtree), modules...)
 	}
+	paths.Include = append(paths.Include, includes...)
+	paths.Exclude = append(paths.Exclude, excludes...)
 
-	gitOutputHistory, err := ExecGitHistory(*directory)
-	if err != nil {
+	// the commits of the merged branches are left out when their merges are
+	// credited, or they would be counted twice
+	revs := Revisions{Since: *since, Until: *until, Range: *revRange, At: *at, FirstParent: *firstParent || *merges == MergesFirstParent}
+	if err := revs.Validate(); err != nil {
 		fmt.Println(chalk.Red, err)
 		os.Exit(1)
 	}
 
-	gitOutputBlameRaw, err := ExecGitBlameRaw(*directory)
-	if err != nil {
-		fmt.Println(chalk.Red, err)
+	hasGit := HasGit()
+	if !hasGit && !*native {
+		fmt.Println(chalk.Yellow, "git was not found, using the built-in reader")
+		*native = true
+	}
+
+	diff := DiffOptions{HistoryWhitespace: *historyWhitespace, BlameWhitespace: *blameWhitespace, BlameMoves: *blameMoves, MergeDiffs: *merges != MergesIgnore}
+	if *allRefsFlag {
+		refs = append(refs, allRefs...)
+	}
+	if *jobs <= 0 {
+		*jobs = runtime.NumCPU()
+	}
+	options := Options{Revisions: revs, Diff: diff, Native: *native, Git: hasGit, Cache: !*noCache, Submodules: *submodules, Paths: paths, Generated: *generated, IgnoreRevsFile: *ignoreRevsFile, IgnoreCommits: ignoreCommits, RefPatterns: refs, Jobs: *jobs, Timings: *timings}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if *coAuthors != CoAuthorsSplit && *coAuthors != CoAuthorsDuplicate && *coAuthors != CoAuthorsIgnore {
+		fmt.Println(chalk.Red, "Unknown co-authors mode ", *coAuthors)
 		os.Exit(1)
 	}
 
-	gitOutputBlameSelected, err := ExecGitBlameSelected(*directory)
-	if err != nil {
-		fmt.Println(chalk.Red, err)
+	if *attribution != AttributionAuthor && *attribution != AttributionCommitter && *attribution != AttributionBoth {
+		fmt.Println(chalk.Red, "Unknown attribution mode ", *attribution)
+		os.Exit(1)
+	}
+
+	if *generated != GeneratedSkip && *generated != GeneratedSeparate && *generated != GeneratedCount {
+		fmt.Println(chalk.Red, "Unknown generated files mode ", *generated)
+		os.Exit(1)
+	}
+
+	if *merges != MergesIgnore && *merges != MergesFirstParent && *merges != MergesIntegrations {
+		fmt.Println(chalk.Red, "Unknown merges mode ", *merges)
+		os.Exit(1)
+	}
+
+	if *outliers != OutliersNone && *outliers != OutliersFlag && *outliers != OutliersCap && *outliers != OutliersExclude {
+		fmt.Println(chalk.Red, "Unknown outliers mode ", *outliers)
 		os.Exit(1)
 	}
 
-	report, err := ParseStats(gitOutputHistory, gitOutputBlameRaw, gitOutputBlameSelected, *subtree, periods, users)
+	parser := NewParser(*subtree, periods, users)
+	parser.SetModules(modules)
+	parser.CoAuthors = *coAuthors
+	parser.Attribution = *attribution
+	parser.Paths = paths
+	parser.Generated = *generated
+	parser.BinaryCommits = *binaryCommits
+	parser.Merges = *merges
+	// a number of lines replaces the default deviations, the lower threshold
+	// applying when both are given
+	if given["outlier-lines"] && !given["outlier-deviations"] {
+		*outlierDeviations = 0
+	}
+	parser.Outliers = OutlierPolicy{Action: *outliers, Deviations: *outlierDeviations, Lines: *outlierLines}
+	if *followRenames {
+		parser.FollowRenames()
+	}
+	for _, directory := range directories {
+		if err := AnalyseRepository(ctx, parser, directory, options); err != nil {
+			fmt.Println(chalk.Red, err)
+			os.Exit(1)
+		}
+	}
+	report := parser.Report
+
+	repos := strings.Join(directories, ", ")
+	// the settings needed to reproduce the counts
+	fmt.Println(chalk.Green, "Line comparison ", diff)
+	if len(parser.Sections) > 1 {
+		for _, section := range parser.Sections {
+			PrintReport(section.Report, repos, section.String())
+		}
+	}
+	PrintReport(report, repos, parser.subtrees())
+	if *languages {
+		PrintLanguages(report)
+	}
+	if *survival {
+		PrintSurvival(report)
+	}
+	PrintOutliers(report)
+	PrintUnmerged(report)
+	if len(directories) > 1 {
+		PrintSubtotals(report)
+	}
+}
 
+// scoreShare is the percentage of a total, 0 when the total is zero, as when
+// the selected commits add as many lines as they delete
+func scoreShare(value, total float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return value * 100.0 / total
+}
+
+// ownedShare is the percentage of the lines owned, 0 when no file was blamed
+func ownedShare(lines, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(lines) * 100.0 / float64(total)
+}
+
+func PrintReport(report *Report, repos string, subtree string) {
 	separator := strings.Repeat("#", 80)
 	fmt.Println(chalk.Green, separator)
-	fmt.Println(chalk.Green, "Summing up contributions for the repository ", *directory, " subtree ", *subtree)
+	fmt.Println(chalk.Green, "Summing up contributions for the repository ", repos, " subtree ", subtree)
 	fmt.Println(chalk.Green, separator)
 	fmt.Println("")
 	table := termtables.CreateTable()
-	table.AddHeaders("Contributor", "Additions - Deletions", "Additions", "Commits", "Score")
+	committed := report.TotalCommitted > 0
+	generated := report.TotalGenerated > 0 || report.TotalGeneratedOwnedLines > 0
+	binary := report.TotalBinaryFiles > 0
+	integrations := report.TotalIntegrations > 0
+	owned := report.TotalOwnedLines > 0 || report.TotalSelectedOwnedLines > 0
+	headers := []interface{}{"Contributor", "Additions - Deletions", "Additions", "Commits", "Score"}
+	if owned {
+		headers = append(headers, "Owned Lines", "Selected Owned Lines")
+	}
+	if committed {
+		headers = append(headers, "Committed", "Committed Additions")
+	}
+	if generated {
+		headers = append(headers, "Generated Additions", "Generated Owned Lines")
+	}
+	if binary {
+		headers = append(headers, "Binary Files", "Binary Bytes")
+	}
+	if integrations {
+		headers = append(headers, "Integrations")
+	}
+	table.AddHeaders(headers...)
 	contributors := make([]Contribution, 0)
 	for _, v := range report.Contributors {
 		for _, contribution := range v.Contributions {
-			if contribution.Commits > 0 {
+			if contribution.Commits > 0 || contribution.Committed > 0 || contribution.GeneratedAdditions > 0 || contribution.GeneratedOwnedLines > 0 || contribution.BinaryFiles > 0 || contribution.Integrations > 0 || contribution.OwnedLines > 0 || contribution.SelectedOwnedLines > 0 {
 				decreaseFactor := 3.0
-				differenceScore := math.Max(float64(contribution.Additions-contribution.Deletions), float64(contribution.Deletions-contribution.Additions) / decreaseFactor) * 100.0 / float64(report.TotalAdditions-report.TotalDeletions)
-				additionScore := float64(contribution.Additions) * 100.0 / float64(report.TotalAdditions)
-				commitScore := float64(contribution.Commits) * 100.0 / float64(report.TotalCommits)
+				differenceScore := scoreShare(math.Max(float64(contribution.Additions-contribution.Deletions), float64(contribution.Deletions-contribution.Additions) / decreaseFactor), float64(report.TotalAdditions-report.TotalDeletions))
+				additionScore := scoreShare(float64(contribution.Additions), float64(report.TotalAdditions))
+				commitScore := scoreShare(contribution.Commits, float64(report.TotalCommits))
 				contribution.SetScores(differenceScore, additionScore, commitScore)
+				if owned {
+					contribution.SetOwnedScores(ownedShare(contribution.OwnedLines, report.TotalOwnedLines), ownedShare(contribution.SelectedOwnedLines, report.TotalSelectedOwnedLines))
+				}
 				contributors = append(contributors, *(contribution))
 				report.TotalScore += contribution.GetScore()
 			}
@@ -484,16 +1362,65 @@ func main() {
 	sort.Sort(OrderByScore(contributors))
 	for index := range contributors {
 		c := contributors[len(contributors)-index-1]
-		if (c.GetScore() > 0) { // hide micro-contributors
-			table.AddRow(c.Name, fmt.Sprintf("%.3f%%", c.DifferenceScore), fmt.Sprintf("%.3f%%", c.AdditionScore), fmt.Sprintf("%.3f%%", c.CommitScore), fmt.Sprintf("%.3f", c.GetScore() * 100.0 / report.TotalScore))
+		if (c.GetScore() > 0 || c.Committed > 0 || c.GeneratedAdditions > 0 || c.GeneratedOwnedLines > 0 || c.BinaryFiles > 0 || c.Integrations > 0 || c.OwnedScore > 0 || c.SelectedOwnedScore > 0) { // hide micro-contributors
+			row := []interface{}{c.Name, fmt.Sprintf("%.3f%%", c.DifferenceScore), fmt.Sprintf("%.3f%%", c.AdditionScore), fmt.Sprintf("%.3f%%", c.CommitScore), fmt.Sprintf("%.3f", c.GetScore() * 100.0 / report.TotalScore)}
+			if owned {
+				row = append(row, fmt.Sprintf("%.3f%%", c.OwnedScore), fmt.Sprintf("%.3f%%", c.SelectedOwnedScore))
+			}
+			if committed {
+				row = append(row, c.Committed, c.CommittedAdditions)
+			}
+			if generated {
+				row = append(row, c.GeneratedAdditions, c.GeneratedOwnedLines)
+			}
+			if binary {
+				row = append(row, c.BinaryFiles, c.BinaryBytes)
+			}
+			if integrations {
+				row = append(row, c.Integrations)
+			}
+			table.AddRow(row...)
 		}
 	}
 
 	table.AddSeparator()
-	table.AddRow("Total", report.TotalAdditions, report.TotalDeletions, report.TotalCommits, "100.0")
+	total := []interface{}{"Total", report.TotalAdditions, report.TotalDeletions, report.TotalCommits, "100.0"}
+	if owned {
+		total = append(total, report.TotalOwnedLines, report.TotalSelectedOwnedLines)
+	}
+	if committed {
+		total = append(total, report.TotalCommitted, report.TotalCommittedAdditions)
+	}
+	if generated {
+		total = append(total, report.TotalGenerated, report.TotalGeneratedOwnedLines)
+	}
+	if binary {
+		total = append(total, report.TotalBinaryFiles, report.TotalBinaryBytes)
+	}
+	if integrations {
+		total = append(total, report.TotalIntegrations)
+	}
+	table.AddRow(total...)
 	table.SetAlign(3, 2)
 	table.SetAlign(3, 3)
 	table.SetAlign(3, 4)
 	table.SetAlign(3, 5)
+	for column := 6; column < len(headers)+1; column++ {
+		table.SetAlign(3, column)
+	}
+	fmt.Println(table.Render())
+}
+
+func PrintSubtotals(report *Report) {
+	table := termtables.CreateTable()
+	table.AddHeaders("Repository", "Additions", "Deletions", "Commits")
+	for _, subtotal := range report.Subtotals {
+		table.AddRow(subtotal.Repo, subtotal.Additions, subtotal.Deletions, subtotal.Commits)
+	}
+	table.AddSeparator()
+	table.AddRow("Total", report.TotalAdditions, report.TotalDeletions, report.TotalCommits)
+	table.SetAlign(3, 2)
+	table.SetAlign(3, 3)
+	table.SetAlign(3, 4)
 	fmt.Println(table.Render())
 }
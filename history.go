@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/ttacon/chalk"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A commit of the git log output with its numstat lines
+
+// historyFormat is the --pretty format of the log, the co-authors being
+// separated by a unit separator
+const historyFormat = "%an|%ad|%(trailers:key=Co-authored-by,valueonly,unfold,separator=%x1f)|%ae|%cn|%ce|%cd|%H|%P"
+
+// historyOptions are the options of git log shaping its output, besides the
+// selected revisions. The raw lines give the blobs of the binary files,
+// whose numstat has no line counts.
+var historyOptions = []string{"-M", "--raw", "--no-abbrev", "--numstat", "--pretty='" + historyFormat + "'"}
+
+// nullHash stands for the missing side of an added or deleted file
+const nullHash = "0000000000000000000000000000000000000000"
+
+// coAuthorSeparator separates the co-authors in the trailers field of the
+// historyFormat
+const coAuthorSeparator = "\x1f"
+
+type FileStat struct {
+	Additions int
+	Deletions int
+	Binary    bool
+	Path      string
+	OldPath   string // where the file was moved from, empty if it wasn't
+	OldBlob   string // from the raw lines, nullHash when added
+	NewBlob   string // nullHash when deleted
+	Size      int    // bytes of a binary file, when they are known
+}
+
+// fileBlobs are the blobs of a file given by a raw line
+type fileBlobs struct {
+	old string
+	new string
+}
+
+// parseRawLine reads a line like ":100644 100644 <old> <new> M\tpath", the
+// path being the destination of renames
+func parseRawLine(line string) (string, fileBlobs, bool) {
+	fields := strings.Split(line, "\t")
+	header := strings.Fields(fields[0])
+	if len(fields) < 2 || len(header) != 5 {
+		return "", fileBlobs{}, false
+	}
+	return unquotePath(fields[len(fields)-1]), fileBlobs{old: header[2], new: header[3]}, true
+}
+
+type CommitStats struct {
+	Author         string
+	Email          string
+	Date           time.Time
+	CoAuthors      []Signature
+	Committer      string
+	CommitterEmail string
+	CommitDate     time.Time
+	Hash           string
+	Parents        []string
+	Files          []FileStat
+}
+
+// HistoryScanner reads the output of `git log --numstat` one commit at a
+// time, so that the memory use doesn't depend on the size of the history
+type HistoryScanner struct {
+	reader  *bufio.Reader
+	pending string
+	Commits int
+}
+
+func NewHistoryScanner(reader io.Reader) *HistoryScanner {
+	return &HistoryScanner{reader: bufio.NewReaderSize(reader, 64*1024)}
+}
+
+func (s *HistoryScanner) readLine() (string, error) {
+	line, err := s.reader.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// isCommitHeader tells the header of a commit from its numstat lines
+func isCommitHeader(line string) bool {
+	return line != "" && !strings.Contains(line, "\t")
+}
+
+func parseCommitHeader(line string) CommitStats {
+	contribAndDate := strings.Split(strings.Replace(line, "'", "", -1), "|")
+	commit := CommitStats{Author: contribAndDate[0]}
+	if len(contribAndDate) > 1 {
+		commit.Date, _ = time.Parse(gitDateLayout, contribAndDate[1])
+	}
+	if len(contribAndDate) > 2 && contribAndDate[2] != "" {
+		for _, coAuthor := range strings.Split(contribAndDate[2], coAuthorSeparator) {
+			commit.CoAuthors = append(commit.CoAuthors, parseSignature(coAuthor))
+		}
+	}
+	if len(contribAndDate) > 3 {
+		commit.Email = contribAndDate[3]
+	}
+	if len(contribAndDate) > 6 {
+		commit.Committer = contribAndDate[4]
+		commit.CommitterEmail = contribAndDate[5]
+		commit.CommitDate, _ = time.Parse(gitDateLayout, contribAndDate[6])
+	} else {
+		// logs without the committer, the author applied the commit
+		commit.Committer = commit.Author
+		commit.CommitterEmail = commit.Email
+		commit.CommitDate = commit.Date
+	}
+	if len(contribAndDate) > 7 {
+		commit.Hash = contribAndDate[7]
+	}
+	if len(contribAndDate) > 8 {
+		commit.Parents = strings.Fields(contribAndDate[8])
+	}
+	return commit
+}
+
+func parseFileStat(line string) (FileStat, bool) {
+	splittedLine := strings.Split(line, "\t")
+	if len(splittedLine) != 3 {
+		return FileStat{}, false
+	}
+	stat := FileStat{Path: unquotePath(splittedLine[2])}
+	if oldPath, newPath := parseRenamePath(stat.Path); oldPath != newPath {
+		stat.OldPath = oldPath
+		stat.Path = newPath
+	}
+	if splittedLine[0] == "-" && splittedLine[1] == "-" {
+		stat.Binary = true
+		return stat, true
+	}
+	var err error
+	stat.Additions, err = strconv.Atoi(splittedLine[0])
+	if err != nil {
+		stat.Additions = 0
+	}
+	stat.Deletions, err = strconv.Atoi(splittedLine[1])
+	if err != nil {
+		stat.Deletions = 0
+	}
+	return stat, true
+}
+
+// unquotePath reverts the C style quoting git applies to unusual paths
+func unquotePath(path string) string {
+	if len(path) < 2 || path[0] != '"' || path[len(path)-1] != '"' {
+		return path
+	}
+	unquoted, err := strconv.Unquote(path)
+	if err != nil {
+		return path
+	}
+	return unquoted
+}
+
+// Scan returns the next commit of the log, or io.EOF once it is over
+func (s *HistoryScanner) Scan() (*CommitStats, error) {
+	header := s.pending
+	s.pending = ""
+	for header == "" {
+		line, err := s.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(line, "\t") {
+			fmt.Println(chalk.Yellow, "Error: unprocessed line (history): ", line)
+			continue
+		}
+		header = line
+	}
+	commit := parseCommitHeader(header)
+	blobs := make(map[string]fileBlobs)
+	for {
+		line, err := s.readLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 {
+			continue
+		}
+		if isCommitHeader(line) {
+			s.pending = line
+			break
+		}
+		// the raw lines come before the numstat of the commit
+		if line[0] == ':' {
+			if path, fileBlobs, ok := parseRawLine(line); ok {
+				blobs[path] = fileBlobs
+				continue
+			}
+		}
+		stat, ok := parseFileStat(line)
+		if !ok {
+			fmt.Println(chalk.Yellow, "Error: unprocessed line (history): ", line)
+			continue
+		}
+		if fileBlobs, exists := blobs[stat.Path]; exists {
+			stat.OldBlob, stat.NewBlob = fileBlobs.old, fileBlobs.new
+		}
+		commit.Files = append(commit.Files, stat)
+	}
+	s.Commits++
+	return &commit, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestHistoryScanner(t *testing.T) {
+	log := "'Contributor1|Mon May 30 22:08:53 2016 +0200'\r\n\r\n5\t1\tgit-stats.go\r\n-\t-\tscreenshot.png\r\n'Contributor2|Mon May 30 22:08:53 2016 +0200'\r\n'Contributor3|Tue May 31 10:00:00 2016 +0200'\r\n\r\n1\t0\tReadme.md"
+	scanner := NewHistoryScanner(strings.NewReader(log))
+
+	commit, err := scanner.Scan()
+	if err != nil {
+		t.Errorf("Scanning a valid log should not fail: %v", err)
+	}
+	if commit.Author != "Contributor1" || len(commit.Files) != 2 {
+		t.Errorf("Unexpected first commit %v", commit)
+	}
+	if commit.Files[0].Additions != 5 || commit.Files[0].Deletions != 1 || commit.Files[0].Path != "git-stats.go" {
+		t.Errorf("Unexpected numstat %v", commit.Files[0])
+	}
+	if !commit.Files[1].Binary {
+		t.Errorf("screenshot.png should be a binary change")
+	}
+	if commit.Date.Year() != 2016 {
+		t.Errorf("The date of the commit was not parsed: %v", commit.Date)
+	}
+
+	// merges come without numstat
+	commit, err = scanner.Scan()
+	if err != nil || commit.Author != "Contributor2" || len(commit.Files) != 0 {
+		t.Errorf("Unexpected second commit %v %v", commit, err)
+	}
+
+	commit, err = scanner.Scan()
+	if err != nil || commit.Author != "Contributor3" || len(commit.Files) != 1 {
+		t.Errorf("Unexpected third commit %v %v", commit, err)
+	}
+
+	if _, err = scanner.Scan(); err != io.EOF {
+		t.Errorf("The end of the log should be reported with io.EOF, got %v", err)
+	}
+	if scanner.Commits != 3 {
+		t.Errorf("The scanner should have read 3 commits and read %v", scanner.Commits)
+	}
+}
+
+func TestCoAuthors(t *testing.T) {
+	log := "Contributor1|Mon May 30 22:08:53 2016 +0200|Contributor2 <two@example.com>\x1fpair <pair@example.com>\n\n5\t2\tgit-stats.go\n"
+	users := UserArray{Users: []User{{Alias: "pair", Name: "Contributor3"}}}
+
+	report, _ := ParseStats(strings.NewReader(log), nil, nil, "/", *NewPeriodArray(), users)
+	if !CheckContributors(report, []string{"Contributor1", "Contributor2", "Contributor3"}) {
+		t.Errorf("The co-authors should be credited through the aliases")
+	}
+	first := report.Contributors["Contributor1"].Contributions[0]
+	third := report.Contributors["Contributor3"].Contributions[0]
+	if first.Additions != 2 || first.Deletions != 1 || third.Additions != 1 || third.Deletions != 0 {
+		t.Errorf("The lines should be split among the co-authors, the remainder going to the author")
+	}
+	if first.Commits != 1.0/3 || report.TotalCommits != 1 || report.TotalAdditions != 5 {
+		t.Errorf("The commit should be split and counted once in the totals")
+	}
+
+	parser := NewParser("/", *NewPeriodArray(), users)
+	parser.CoAuthors = CoAuthorsDuplicate
+	parser.ParseHistory(strings.NewReader(log))
+	third = parser.Report.Contributors["Contributor3"].Contributions[0]
+	if third.Additions != 5 || third.Commits != 1 || parser.Report.TotalAdditions != 5 {
+		t.Errorf("Each co-author should be credited the whole commit")
+	}
+
+	parser = NewParser("/", *NewPeriodArray(), users)
+	parser.CoAuthors = CoAuthorsIgnore
+	parser.ParseHistory(strings.NewReader(log))
+	if len(parser.Report.Contributors) != 1 {
+		t.Errorf("Only the author should be credited when the co-authors are ignored")
+	}
+}
+
+func TestAttribution(t *testing.T) {
+	log := "Contributor1|Mon May 30 22:08:53 2016 +0200||one@example.com|Maintainer|maintainer@example.com|Wed Jun 1 09:00:00 2016 +0200\n\n5\t2\tgit-stats.go\n"
+	periods := PeriodArray{Periods: []Period{
+		{User: "Contributor1", Start: "2016-05-01", End: "2016-05-31", Alias: "May"},
+		{User: "Maintainer", Start: "2016-05-01", End: "2016-05-31", Alias: "May"},
+	}}
+
+	parser := NewParser("/", periods, *NewUserArray())
+	parser.Attribution = AttributionCommitter
+	parser.ParseHistory(strings.NewReader(log))
+	if !CheckContributors(parser.Report, []string{"Maintainer"}) {
+		t.Errorf("The committer alone should be credited")
+	}
+	if contributions := parser.Report.Contributors["Maintainer"].Contributions; contributions[0].Additions != 5 || contributions[1].Additions != 0 {
+		t.Errorf("The commit date should be used for the periods, out of May")
+	}
+
+	parser = NewParser("/", periods, *NewUserArray())
+	parser.Attribution = AttributionBoth
+	parser.ParseHistory(strings.NewReader(log))
+	author := parser.Report.Contributors["Contributor1"].Contributions[1]
+	committer := parser.Report.Contributors["Maintainer"].Contributions[0]
+	if author.Additions != 5 || author.Commits != 1 || author.Committed != 0 {
+		t.Errorf("The author should be credited in the May period: %v", author)
+	}
+	if committer.Committed != 1 || committer.CommittedAdditions != 5 || committer.Commits != 0 || parser.Report.TotalCommitted != 1 {
+		t.Errorf("The committer should be credited apart: %v", committer)
+	}
+}
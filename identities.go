@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/ttacon/chalk"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Identity is a name and an email as recorded in the commits
+type Identity struct {
+	Name  string
+	Email string
+}
+
+func (i Identity) String() string {
+	if i.Email == "" {
+		return i.Name
+	}
+	return fmt.Sprintf("%v <%v>", i.Name, i.Email)
+}
+
+// .mailmap
+
+type mailmapEntry struct {
+	properName  string
+	properEmail string
+}
+
+// Mailmap maps the identities of the commits to the canonical ones, as
+// described in gitmailmap(5)
+type Mailmap struct {
+	byEmail map[string]mailmapEntry
+	byPair  map[Identity]mailmapEntry // commit name in lower case
+}
+
+func NewMailmap() *Mailmap {
+	return &Mailmap{byEmail: make(map[string]mailmapEntry), byPair: make(map[Identity]mailmapEntry)}
+}
+
+// ParseMailmap reads the lines of a .mailmap:
+//
+//	Proper Name <commit@email>
+//	<proper@email> <commit@email>
+//	Proper Name <proper@email> <commit@email>
+//	Proper Name <proper@email> Commit Name <commit@email>
+func ParseMailmap(reader io.Reader) (*Mailmap, error) {
+	mailmap := NewMailmap()
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if comment := strings.IndexByte(line, '#'); comment >= 0 {
+			line = line[:comment]
+		}
+		var names, emails []string
+		for {
+			open := strings.IndexByte(line, '<')
+			closing := strings.IndexByte(line, '>')
+			if open < 0 || closing < open {
+				break
+			}
+			names = append(names, strings.TrimSpace(line[:open]))
+			emails = append(emails, strings.ToLower(strings.TrimSpace(line[open+1:closing])))
+			line = line[closing+1:]
+		}
+		if len(emails) == 0 {
+			continue
+		}
+		entry := mailmapEntry{properName: names[0]}
+		commitName, commitEmail := "", emails[0]
+		if len(emails) > 1 {
+			entry.properEmail = emails[0]
+			commitName, commitEmail = names[1], emails[1]
+		}
+		if commitName != "" {
+			mailmap.byPair[Identity{Name: strings.ToLower(commitName), Email: commitEmail}] = entry
+		} else {
+			mailmap.byEmail[commitEmail] = entry
+		}
+	}
+	return mailmap, scanner.Err()
+}
+
+// ReadMailmap reads the .mailmap of the work tree of a repository, or of its
+// HEAD for bare repositories. A repository without a .mailmap gets an empty one.
+func ReadMailmap(repo string) (*Mailmap, error) {
+	file, err := os.Open(filepath.Join(repo, ".mailmap"))
+	if err == nil {
+		defer file.Close()
+		return ParseMailmap(file)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	content, err := ReadFileAt(repo, "HEAD", ".mailmap")
+	if err != nil || content == nil {
+		return NewMailmap(), nil
+	}
+	return ParseMailmap(strings.NewReader(string(content)))
+}
+
+// ReadFileAt returns the content of a file in the tree of a revision, nil if
+// the file doesn't exist
+func ReadFileAt(repo, rev, path string) ([]byte, error) {
+	store, err := OpenObjectStore(repo)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	hash, err := store.ResolveRevision(rev)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := store.ReadCommit(hash)
+	if err != nil {
+		return nil, err
+	}
+	tree := commit.Tree
+	parts := strings.Split(path, "/")
+	for index, part := range parts {
+		entries, err := store.ReadTree(tree)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, entry := range entries {
+			if entry.Name != part {
+				continue
+			}
+			if index == len(parts)-1 {
+				if entry.IsTree() || entry.IsSubmodule() {
+					return nil, nil
+				}
+				return store.ReadBlob(entry.Hash)
+			}
+			if !entry.IsTree() {
+				return nil, nil
+			}
+			tree = entry.Hash
+			found = true
+			break
+		}
+		if !found {
+			return nil, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *Mailmap) Map(identity Identity) Identity {
+	email := strings.ToLower(identity.Email)
+	entry, exists := m.byPair[Identity{Name: strings.ToLower(identity.Name), Email: email}]
+	if !exists {
+		entry, exists = m.byEmail[email]
+	}
+	if !exists {
+		return identity
+	}
+	if entry.properName != "" {
+		identity.Name = entry.properName
+	}
+	if entry.properEmail != "" {
+		identity.Email = entry.properEmail
+	}
+	return identity
+}
+
+// Identities resolves the identities of the commits into contributors: the
+// .mailmap of the repository comes first, then the users of the
+// configuration, matched on a name and email pair, an email or a name.
+type Identities struct {
+	mailmap *Mailmap
+	byName  map[string]string
+	byEmail map[string]string
+	byPair  map[Identity]string
+}
+
+func NewIdentities(users UserArray) *Identities {
+	identities := &Identities{mailmap: NewMailmap(), byName: make(map[string]string), byEmail: make(map[string]string), byPair: make(map[Identity]string)}
+	for _, user := range users.Users {
+		email := strings.ToLower(user.Email)
+		if user.Alias != "" && email != "" {
+			identities.byPair[Identity{Name: user.Alias, Email: email}] = user.Name
+		} else if email != "" {
+			identities.byEmail[email] = user.Name
+		} else {
+			identities.byName[user.Alias] = user.Name
+		}
+	}
+	return identities
+}
+
+func (i *Identities) SetMailmap(mailmap *Mailmap) {
+	i.mailmap = mailmap
+}
+
+// Resolve returns the contributor credited for an identity, false when the
+// configuration skips it
+func (i *Identities) Resolve(identity Identity) (string, bool) {
+	identity = i.mailmap.Map(identity)
+	email := strings.ToLower(identity.Email)
+	name, exists := i.byPair[Identity{Name: identity.Name, Email: email}]
+	if !exists && email != "" {
+		name, exists = i.byEmail[email]
+	}
+	if !exists {
+		name, exists = i.byName[identity.Name]
+	}
+	if !exists {
+		return identity.Name, true
+	}
+	if name == "" {
+		fmt.Println(chalk.Yellow, "Skip user: ", identity)
+		return "", false
+	}
+	return name, true
+}
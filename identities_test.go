@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const testMailmap = `# comment
+Jean Le Feuvre <jeanlf@example.com>
+<cyril@example.com> <cconcolato@old.example.com>
+Romain Bouqueau <romain@example.com> <rbouqueau@example.com>
+Aurelien David <aurelien@example.com> adavid <shared@example.com>
+`
+
+func TestMailmap(t *testing.T) {
+	mailmap, err := ParseMailmap(strings.NewReader(testMailmap))
+	if err != nil {
+		t.Errorf("Parsing a valid mailmap should not fail: %v", err)
+	}
+	tests := []struct {
+		commit   Identity
+		expected Identity
+	}{
+		{Identity{"jeanlf", "JeanLF@example.com"}, Identity{"Jean Le Feuvre", "JeanLF@example.com"}},
+		{Identity{"cconcolato", "cconcolato@old.example.com"}, Identity{"cconcolato", "cyril@example.com"}},
+		{Identity{"rb", "rbouqueau@example.com"}, Identity{"Romain Bouqueau", "romain@example.com"}},
+		{Identity{"ADavid", "shared@example.com"}, Identity{"Aurelien David", "aurelien@example.com"}},
+		{Identity{"someone else", "shared@example.com"}, Identity{"someone else", "shared@example.com"}},
+	}
+	for _, test := range tests {
+		if mapped := mailmap.Map(test.commit); mapped != test.expected {
+			t.Errorf("%v should be mapped to %v and was mapped to %v", test.commit, test.expected, mapped)
+		}
+	}
+}
+
+func TestIdentities(t *testing.T) {
+	users := UserArray{Users: []User{
+		{Alias: "lefeuvre", Name: "Jean Le Feuvre"},
+		{Email: "jeanlf@example.com", Name: "Jean Le Feuvre"},
+		{Alias: "build", Email: "ci@example.com", Name: ""},
+	}}
+	identities := NewIdentities(users)
+	mailmap, _ := ParseMailmap(strings.NewReader("<jeanlf@example.com> <jean@old.example.com>\n"))
+	identities.SetMailmap(mailmap)
+
+	tests := []struct {
+		identity Identity
+		name     string
+		credited bool
+	}{
+		{Identity{"lefeuvre", "other@example.com"}, "Jean Le Feuvre", true},
+		{Identity{"jlf", "JEANLF@example.com"}, "Jean Le Feuvre", true},
+		{Identity{"jlf", "jean@old.example.com"}, "Jean Le Feuvre", true},
+		{Identity{"build", "ci@example.com"}, "", false},
+		{Identity{"build", "build@example.com"}, "build", true},
+	}
+	for _, test := range tests {
+		name, credited := identities.Resolve(test.identity)
+		if name != test.name || credited != test.credited {
+			t.Errorf("%v should resolve to %v (%v) and resolved to %v (%v)", test.identity, test.name, test.credited, name, credited)
+		}
+	}
+}
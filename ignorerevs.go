@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/ttacon/chalk"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Commits left out of the history and passed through by the blame, such as
+// mass reformatting
+
+type IgnoredCommitArray struct {
+	Commits []string `json:"ignore_commits"`
+}
+
+func DecodeIgnoredCommits(jsonBlob []byte) ([]string, error) {
+	var ignored IgnoredCommitArray
+	err := json.Unmarshal(jsonBlob, &ignored)
+	return ignored.Commits, err
+}
+
+// ReadIgnoreRevs reads a file in the format of blame.ignoreRevsFile: a
+// commit per line, # starting a comment
+func ReadIgnoreRevs(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var revs []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if comment := strings.IndexByte(line, '#'); comment >= 0 {
+			line = line[:comment]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			revs = append(revs, line)
+		}
+	}
+	return revs, scanner.Err()
+}
+
+// ResolveCommits returns the full hash of the revisions which are commits of
+// the repository, the others being ignored as they may belong to another
+// repository of the run
+func ResolveCommits(repo string, revs []string, useGit bool) ([]string, error) {
+	if len(revs) == 0 {
+		return nil, nil
+	}
+	var hashes []string
+	if !useGit {
+		store, err := OpenObjectStore(repo)
+		if err != nil {
+			return nil, err
+		}
+		defer store.Close()
+		for _, rev := range revs {
+			if hash, err := store.ResolveRevision(rev); err == nil {
+				hashes = append(hashes, hash)
+			}
+		}
+		return hashes, nil
+	}
+	command := exec.Command("git", "-C", repo, "cat-file", "--batch-check=%(objectname) %(objecttype)")
+	command.Stdin = strings.NewReader(strings.Join(revs, "\n") + "\n")
+	out, err := command.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git cat-file: %v", err)
+	}
+	// <hash> commit, or <rev> missing / ambiguous
+	for _, line := range strings.Split(string(out), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 && fields[1] == "commit" {
+			hashes = append(hashes, fields[0])
+		}
+	}
+	return hashes, nil
+}
+
+// ignoredCommits gathers the ignored commits of a repository: those of the
+// configuration and those of the ignore-revs file, relative to the repository
+func ignoredCommits(repo string, options Options) ([]string, error) {
+	revs := append([]string(nil), options.IgnoreCommits...)
+	if options.IgnoreRevsFile != "" {
+		file := options.IgnoreRevsFile
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(repo, file)
+		}
+		fileRevs, err := ReadIgnoreRevs(file)
+		if err != nil {
+			fmt.Println(chalk.Yellow, "Skip the ignored revisions: ", err)
+		}
+		revs = append(revs, fileRevs...)
+	}
+	return ResolveCommits(repo, revs, options.Git)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadIgnoreRevs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "git-stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, ".git-blame-ignore-revs")
+	ioutil.WriteFile(file, []byte("# clang-format\n1111111111111111111111111111111111111111\n\n  2222222222222222222222222222222222222222 # tabs\n"), 0644)
+
+	revs, err := ReadIgnoreRevs(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(revs, []string{"1111111111111111111111111111111111111111", "2222222222222222222222222222222222222222"}) {
+		t.Errorf("Unexpected ignored revisions %v", revs)
+	}
+}
+
+func TestIgnoredCommits(t *testing.T) {
+	log := "Contributor1|Mon May 30 22:08:53 2016 +0200||one@example.com|Contributor1|one@example.com|Mon May 30 22:08:53 2016 +0200|aaaa\n\n500\t500\tsrc/main.c\n" +
+		"Contributor2|Tue May 31 22:08:53 2016 +0200||two@example.com|Contributor2|two@example.com|Tue May 31 22:08:53 2016 +0200|bbbb\n\n3\t1\tsrc/main.c\n"
+
+	parser := NewParser("/", *NewPeriodArray(), *NewUserArray())
+	parser.SetIgnoredCommits([]string{"aaaa"})
+	parser.ParseHistory(strings.NewReader(log))
+	if !CheckContributors(parser.Report, []string{"Contributor2"}) || parser.Report.TotalAdditions != 3 || parser.Report.TotalCommits != 1 {
+		t.Errorf("The ignored commit should be left out of the history")
+	}
+
+	revs := Revisions{Ignored: []string{"aaaa"}}
+	if args := revs.BlameArgs("cccc"); !reflect.DeepEqual(args, []string{"--root", "--ignore-rev", "aaaa", "cccc"}) {
+		t.Errorf("Unexpected blame arguments %v", args)
+	}
+}
+
+func TestResolveAbbreviatedCommits(t *testing.T) {
+	repo := newTestRepo(t, []testCommit{
+		{Author: "Alice", Date: "2016-05-30T10:00:00", Files: map[string]string{"main.c": "int a;\n"}},
+		{Author: "Bob", Date: "2016-05-31T10:00:00", Files: map[string]string{"main.c": "int b;\n"}},
+	})
+	out, _ := runGit(repo, "rev-parse", "HEAD~1")
+	hash := strings.TrimSpace(string(out))
+	for _, step := range []string{"loose", "packed"} {
+		if step == "packed" {
+			if out, err := exec.Command("git", "-C", repo, "gc", "-q").CombinedOutput(); err != nil {
+				t.Fatalf("git gc: %v %s", err, out)
+			}
+		}
+		hashes, err := ResolveCommits(repo, []string{hash[:7], "0000000"}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(hashes, []string{hash}) {
+			t.Errorf("%v: the abbreviated hash should be resolved without git, got %v", step, hashes)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"github.com/RodolpheFouquet/termtables"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Languages of the files, told by their name or their extension
+
+const otherLanguage = "Other"
+
+var languageByName = map[string]string{
+	"makefile":       "Makefile",
+	"gnumakefile":    "Makefile",
+	"makefile.am":    "Makefile",
+	"makefile.in":    "Makefile",
+	"configure":      "Autoconf",
+	"configure.ac":   "Autoconf",
+	"configure.in":   "Autoconf",
+	"cmakelists.txt": "CMake",
+	"dockerfile":     "Dockerfile",
+}
+
+var languageByExtension = map[string]string{
+	".c":     "C",
+	".h":     "C",
+	".cpp":   "C++",
+	".cc":    "C++",
+	".cxx":   "C++",
+	".c++":   "C++",
+	".hpp":   "C++",
+	".hh":    "C++",
+	".hxx":   "C++",
+	".ipp":   "C++",
+	".m":     "Objective-C",
+	".mm":    "Objective-C++",
+	".cs":    "C#",
+	".go":    "Go",
+	".rs":    "Rust",
+	".java":  "Java",
+	".kt":    "Kotlin",
+	".kts":   "Kotlin",
+	".scala": "Scala",
+	".swift": "Swift",
+	".js":    "JavaScript",
+	".mjs":   "JavaScript",
+	".cjs":   "JavaScript",
+	".jsx":   "JavaScript",
+	".ts":    "TypeScript",
+	".tsx":   "TypeScript",
+	".py":    "Python",
+	".rb":    "Ruby",
+	".php":   "PHP",
+	".pl":    "Perl",
+	".pm":    "Perl",
+	".lua":   "Lua",
+	".sh":    "Shell",
+	".bash":  "Shell",
+	".zsh":   "Shell",
+	".ps1":   "PowerShell",
+	".bat":   "Batchfile",
+	".cmd":   "Batchfile",
+	".s":     "Assembly",
+	".asm":   "Assembly",
+	".html":  "HTML",
+	".htm":   "HTML",
+	".css":   "CSS",
+	".scss":  "CSS",
+	".less":  "CSS",
+	".mk":    "Makefile",
+	".mak":   "Makefile",
+	".m4":    "Autoconf",
+	".cmake": "CMake",
+	".sql":   "SQL",
+	".proto": "Protocol Buffers",
+	".json":  "JSON",
+	".xml":   "XML",
+	".yml":   "YAML",
+	".yaml":  "YAML",
+	".toml":  "TOML",
+	".md":    "Markdown",
+	".rst":   "reStructuredText",
+	".txt":   "Text",
+}
+
+// Language returns the language of a file, otherLanguage when unknown
+func Language(name string) string {
+	base := strings.ToLower(path.Base(name))
+	if language, exists := languageByName[base]; exists {
+		return language
+	}
+	if language, exists := languageByExtension[path.Ext(base)]; exists {
+		return language
+	}
+	return otherLanguage
+}
+
+// LanguageStats are the changes made in a language
+type LanguageStats struct {
+	Additions int
+	Deletions int
+}
+
+// IncrementLanguage credits the changes to a file of a language, divided
+// like IncrementSharedCounters
+func (r *Report) IncrementLanguage(names []string, language string, additions, deletions int, split bool, date time.Time) {
+	for index, name := range names {
+		if !r.HasContributor(name) {
+			continue
+		}
+		contribAdditions, contribDeletions := additions, deletions
+		if split {
+			contribAdditions = splitShare(additions, len(names), index)
+			contribDeletions = splitShare(deletions, len(names), index)
+		}
+		contrib := GetContribution(r.Contributors[name].Contributions, date)
+		if contrib.Languages == nil {
+			contrib.Languages = make(map[string]*LanguageStats)
+		}
+		if contrib.Languages[language] == nil {
+			contrib.Languages[language] = &LanguageStats{}
+		}
+		contrib.Languages[language].Additions += contribAdditions
+		contrib.Languages[language].Deletions += contribDeletions
+	}
+	if r.Languages == nil {
+		r.Languages = make(map[string]*LanguageStats)
+	}
+	if r.Languages[language] == nil {
+		r.Languages[language] = &LanguageStats{}
+	}
+	r.Languages[language].Additions += additions
+	r.Languages[language].Deletions += deletions
+}
+
+// PrintLanguages prints a table per language, the languages with the most
+// additions first
+func PrintLanguages(report *Report) {
+	languages := make([]string, 0, len(report.Languages))
+	for language := range report.Languages {
+		languages = append(languages, language)
+	}
+	sort.Slice(languages, func(i, j int) bool {
+		a, b := report.Languages[languages[i]], report.Languages[languages[j]]
+		if a.Additions != b.Additions {
+			return a.Additions > b.Additions
+		}
+		return languages[i] < languages[j]
+	})
+	for _, language := range languages {
+		total := report.Languages[language]
+		var contributions []*Contribution
+		for _, contributor := range report.Contributors {
+			for _, contribution := range contributor.Contributions {
+				if stats := contribution.Languages[language]; stats != nil && stats.Additions+stats.Deletions > 0 {
+					contributions = append(contributions, contribution)
+				}
+			}
+		}
+		sort.Slice(contributions, func(i, j int) bool {
+			a, b := contributions[i].Languages[language], contributions[j].Languages[language]
+			if a.Additions != b.Additions {
+				return a.Additions > b.Additions
+			}
+			return contributions[i].Name < contributions[j].Name
+		})
+
+		fmt.Println(language)
+		table := termtables.CreateTable()
+		table.AddHeaders("Contributor", "Additions", "Deletions", "Share of the additions")
+		for _, contribution := range contributions {
+			stats := contribution.Languages[language]
+			share := 0.0
+			if total.Additions > 0 {
+				share = float64(stats.Additions) * 100.0 / float64(total.Additions)
+			}
+			table.AddRow(contribution.Name, stats.Additions, stats.Deletions, fmt.Sprintf("%.3f%%", share))
+		}
+		table.AddSeparator()
+		table.AddRow("Total", total.Additions, total.Deletions, "100.0%")
+		table.SetAlign(3, 2)
+		table.SetAlign(3, 3)
+		table.SetAlign(3, 4)
+		fmt.Println(table.Render())
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLanguage(t *testing.T) {
+	cases := map[string]string{
+		"src/main.c":           "C",
+		"include/api.h":        "C",
+		"src/ui/view.cpp":      "C++",
+		"web/app.js":           "JavaScript",
+		"Makefile":             "Makefile",
+		"src/Makefile.am":      "Makefile",
+		"configure.ac":         "Autoconf",
+		"build/CMakeLists.txt": "CMake",
+		"README":               otherLanguage,
+		"doc/logo.PNG":         otherLanguage,
+	}
+	for name, language := range cases {
+		if Language(name) != language {
+			t.Errorf("%v should be %v, got %v", name, language, Language(name))
+		}
+	}
+
+	filter := PathFilter{SelectedLanguages: []string{"go"}}
+	if !filter.MatchSelected("cmd/main.go") || filter.MatchSelected("src/main.c") {
+		t.Errorf("The selected languages should replace the default patterns")
+	}
+	for name, selected := range map[string]bool{"src/Makefile.in": true, "build/configure.ac": true, "tools/configure-deps.sh": true, "doc/index.md": false, "src/main.cc": false, "src/main.hpp": false, "rules.mk": false, "ui/app.jsx": false} {
+		if (PathFilter{}).MatchSelected(name) != selected {
+			t.Errorf("The default selection of %v should be %v, like the former regular expression", name, selected)
+		}
+	}
+}
+
+func TestLanguageBreakdown(t *testing.T) {
+	log := "Contributor1|Mon May 30 22:08:53 2016 +0200|Contributor2 <two@example.com>\n\n4\t2\tsrc/main.c\n3\t0\tMakefile\n"
+	report, _ := ParseStats(strings.NewReader(log), nil, nil, "/", *NewPeriodArray(), *NewUserArray())
+	if c := report.Languages["C"]; c == nil || c.Additions != 4 || c.Deletions != 2 {
+		t.Errorf("Unexpected C total %v", c)
+	}
+	first := report.Contributors["Contributor1"].Contributions[0].Languages
+	second := report.Contributors["Contributor2"].Contributions[0].Languages
+	if first["C"].Additions != 2 || second["C"].Additions != 2 || first["Makefile"].Additions != 2 || second["Makefile"].Additions != 1 {
+		t.Errorf("The changes should be split by language among the co-authors")
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import "time"
+
+// Merges have no diff in git log by default. They can be diffed against
+// their first parent, crediting the conflict resolutions and the merged
+// changes to whom merged them along the mainline only, or counted as
+// integrations.
+
+const (
+	MergesIgnore       = "ignore"
+	MergesFirstParent  = "first-parent"
+	MergesIntegrations = "integrations"
+)
+
+// creditIntegration counts a merge once in each report of the files it
+// brings, without its lines
+func (p *Parser) creditIntegration(commit *CommitStats, contributors []string, files []commitFile) {
+	credit := p.creditOf(commit, contributors)
+	counted := make(map[*Report]bool)
+	for _, file := range files {
+		for _, report := range file.reports {
+			if counted[report] {
+				continue
+			}
+			counted[report] = true
+			for _, contributor := range contributors {
+				report.AddContributor(contributor, p.periodMap)
+			}
+			report.IncrementIntegration(contributors, credit.date)
+		}
+	}
+}
+
+func (r *Report) IncrementIntegration(names []string, date time.Time) {
+	for _, name := range names {
+		if r.HasContributor(name) {
+			GetContribution(r.Contributors[name].Contributions, date).Integrations++
+		}
+	}
+	r.TotalIntegrations++
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMerges(t *testing.T) {
+	log := "Carol|Tue May 31 22:08:53 2016 +0200||c@example.com|Carol|c@example.com|Tue May 31 22:08:53 2016 +0200|cccc|aaaa bbbb\n\n4\t1\tsrc/main.c\n" +
+		"Bob|Mon May 30 22:08:53 2016 +0200||b@example.com|Bob|b@example.com|Mon May 30 22:08:53 2016 +0200|bbbb|0000\n\n3\t0\tsrc/main.c\n"
+
+	parser := NewParser("/", *NewPeriodArray(), *NewUserArray())
+	parser.ParseHistory(strings.NewReader(log))
+	if !CheckContributors(parser.Report, []string{"Bob"}) || parser.Report.TotalCommits != 1 {
+		t.Errorf("The merges should be ignored by default")
+	}
+
+	parser = NewParser("/", *NewPeriodArray(), *NewUserArray())
+	parser.Merges = MergesFirstParent
+	parser.ParseHistory(strings.NewReader(log))
+	if carol := parser.Report.Contributors["Carol"]; carol == nil || carol.Contributions[0].Additions != 4 || parser.Report.TotalCommits != 2 {
+		t.Errorf("The diff of the merge should be credited to whom merged")
+	}
+
+	parser = NewParser("/", *NewPeriodArray(), *NewUserArray())
+	parser.Merges = MergesIntegrations
+	parser.ParseHistory(strings.NewReader(log))
+	carol := parser.Report.Contributors["Carol"].Contributions[0]
+	if carol.Integrations != 1 || carol.Additions != 0 || carol.Commits != 0 || parser.Report.TotalIntegrations != 1 || parser.Report.TotalCommits != 1 {
+		t.Errorf("The merge should be counted as an integration alone: %v", carol)
+	}
+
+	parser = NewParser("/doc", *NewPeriodArray(), *NewUserArray())
+	parser.Merges = MergesIntegrations
+	parser.ParseHistory(strings.NewReader(log))
+	if parser.Report.TotalIntegrations != 0 {
+		t.Errorf("A merge outside of the subtree should not be counted")
+	}
+}
+
+func TestFirstParent(t *testing.T) {
+	revs := Revisions{FirstParent: true}
+	if args := revs.LogArgs(); !reflect.DeepEqual(args, []string{"--first-parent", "HEAD"}) {
+		t.Errorf("Unexpected log arguments %v", args)
+	}
+	if args := revs.BlameArgs("abcdef"); !reflect.DeepEqual(args, []string{"--root", "--first-parent", "abcdef"}) {
+		t.Errorf("Unexpected blame arguments %v", args)
+	}
+	if args := (DiffOptions{MergeDiffs: true}).HistoryArgs(); args[len(args)-1] != "--diff-merges=first-parent" {
+		t.Errorf("Unexpected log arguments %v", args)
+	}
+}
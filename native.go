@@ -0,0 +1,844 @@
+package main
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Pure Go equivalent of `git log --numstat`, for machines without git
+
+const gitDateLayout = "Mon Jan 2 15:04:05 2006 -0700"
+
+type commitQueue []*Commit
+
+func (q commitQueue) Len() int { return len(q) }
+func (q commitQueue) Less(i, j int) bool {
+	return q[i].Committer.When.After(q[j].Committer.When)
+}
+func (q commitQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *commitQueue) Push(x interface{}) { *q = append(*q, x.(*Commit)) }
+func (q *commitQueue) Pop() interface{} {
+	old := *q
+	commit := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return commit
+}
+
+// WalkCommits visits the commits reachable from the given heads but not
+// from the hidden ones, newest committer date first, like git log does by
+// default. With firstParent, only the first parent of merges is followed.
+func (s *ObjectStore) WalkCommits(heads []string, hidden map[string]bool, firstParent bool, visit func(*Commit) error) error {
+	seen := make(map[string]bool)
+	queue := &commitQueue{}
+	push := func(hash string) error {
+		if seen[hash] || hidden[hash] {
+			return nil
+		}
+		seen[hash] = true
+		commit, err := s.ReadCommit(hash)
+		if err != nil {
+			return err
+		}
+		heap.Push(queue, commit)
+		return nil
+	}
+	for _, head := range heads {
+		if err := push(head); err != nil {
+			return err
+		}
+	}
+	for queue.Len() > 0 {
+		commit := heap.Pop(queue).(*Commit)
+		if err := visit(commit); err != nil {
+			return err
+		}
+		parents := commit.Parents
+		if firstParent && len(parents) > 1 {
+			parents = parents[:1]
+		}
+		for _, parent := range parents {
+			if err := push(parent); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type fileChange struct {
+	Path    string
+	OldPath string // set on renames
+	OldHash string
+	NewHash string
+	OldMode uint32
+	NewMode uint32
+}
+
+func (s *ObjectStore) readTreeOrEmpty(hash string) ([]TreeEntry, error) {
+	if hash == "" {
+		return nil, nil
+	}
+	return s.ReadTree(hash)
+}
+
+func (s *ObjectStore) addTree(hash, prefix string, deleted bool, changes *[]fileChange) error {
+	entries, err := s.ReadTree(hash)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsTree() {
+			if err := s.addTree(entry.Hash, prefix+entry.Name+"/", deleted, changes); err != nil {
+				return err
+			}
+		} else if deleted {
+			*changes = append(*changes, fileChange{Path: prefix + entry.Name, OldHash: entry.Hash, OldMode: entry.Mode})
+		} else {
+			*changes = append(*changes, fileChange{Path: prefix + entry.Name, NewHash: entry.Hash, NewMode: entry.Mode})
+		}
+	}
+	return nil
+}
+
+// DiffTrees lists the files that differ between two trees, an empty hash
+// standing for the empty tree
+func (s *ObjectStore) DiffTrees(oldTree, newTree, prefix string, changes *[]fileChange) error {
+	if oldTree == newTree {
+		return nil
+	}
+	oldEntries, err := s.readTreeOrEmpty(oldTree)
+	if err != nil {
+		return err
+	}
+	newEntries, err := s.readTreeOrEmpty(newTree)
+	if err != nil {
+		return err
+	}
+	oldByName := make(map[string]TreeEntry)
+	for _, entry := range oldEntries {
+		oldByName[entry.Name] = entry
+	}
+	newByName := make(map[string]TreeEntry)
+	for _, entry := range newEntries {
+		newByName[entry.Name] = entry
+	}
+	for _, entry := range oldEntries {
+		other, exists := newByName[entry.Name]
+		if exists && other.IsTree() == entry.IsTree() {
+			continue
+		}
+		if entry.IsTree() {
+			if err := s.addTree(entry.Hash, prefix+entry.Name+"/", true, changes); err != nil {
+				return err
+			}
+		} else {
+			*changes = append(*changes, fileChange{Path: prefix + entry.Name, OldHash: entry.Hash, OldMode: entry.Mode})
+		}
+	}
+	for _, entry := range newEntries {
+		other, exists := oldByName[entry.Name]
+		if exists && other.IsTree() == entry.IsTree() {
+			if other.Hash == entry.Hash && other.Mode == entry.Mode {
+				continue
+			}
+			if entry.IsTree() {
+				if err := s.DiffTrees(other.Hash, entry.Hash, prefix+entry.Name+"/", changes); err != nil {
+					return err
+				}
+			} else {
+				*changes = append(*changes, fileChange{Path: prefix + entry.Name, OldHash: other.Hash, NewHash: entry.Hash, OldMode: other.Mode, NewMode: entry.Mode})
+			}
+			continue
+		}
+		if entry.IsTree() {
+			if err := s.addTree(entry.Hash, prefix+entry.Name+"/", false, changes); err != nil {
+				return err
+			}
+		} else {
+			*changes = append(*changes, fileChange{Path: prefix + entry.Name, NewHash: entry.Hash, NewMode: entry.Mode})
+		}
+	}
+	return nil
+}
+
+// git considers a blob binary when its first 8000 bytes contain a NUL
+func isBinary(content []byte) bool {
+	if len(content) > 8000 {
+		content = content[:8000]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
+
+func (s *ObjectStore) readSide(hash string, mode uint32) ([]byte, error) {
+	if hash == "" {
+		return nil, nil
+	}
+	if mode&0170000 == 0160000 {
+		return []byte(fmt.Sprintf("Subproject commit %v\n", hash)), nil
+	}
+	return s.ReadBlob(hash)
+}
+
+// Numstat returns the added and deleted lines of a change, binary being set
+// when git would print "-" for both. Like git diff -w, the whitespace can be
+// ignored when comparing the lines.
+func (s *ObjectStore) Numstat(change fileChange, ignoreWhitespace bool) (additions, deletions int, binary bool, err error) {
+	if change.OldHash == change.NewHash {
+		return 0, 0, false, nil
+	}
+	oldContent, err := s.readSide(change.OldHash, change.OldMode)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	newContent, err := s.readSide(change.NewHash, change.NewMode)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if isBinary(oldContent) || isBinary(newContent) {
+		return 0, 0, true, nil
+	}
+	oldLines, newLines := splitLines(oldContent), splitLines(newContent)
+	if ignoreWhitespace {
+		oldLines, newLines = stripWhitespace(oldLines), stripWhitespace(newLines)
+	}
+	additions, deletions = countLineChanges(oldLines, newLines)
+	return additions, deletions, false, nil
+}
+
+// stripWhitespace removes the whitespace of the lines, their terminator
+// apart
+func stripWhitespace(lines []string) []string {
+	stripped := make([]string, len(lines))
+	for i, line := range lines {
+		stripped[i] = strings.Map(func(r rune) rune {
+			if r != '\n' && unicode.IsSpace(r) {
+				return -1
+			}
+			return r
+		}, line)
+	}
+	return stripped
+}
+
+// splitLines keeps the line terminators so that a missing final newline
+// counts as a change, as it does in git
+func splitLines(content []byte) []string {
+	var lines []string
+	for len(content) > 0 {
+		end := bytes.IndexByte(content, '\n')
+		if end < 0 {
+			lines = append(lines, string(content))
+			break
+		}
+		lines = append(lines, string(content[:end+1]))
+		content = content[end+1:]
+	}
+	return lines
+}
+
+// countLineChanges counts the lines added and deleted between two files the
+// way git's xdiff does, with the same heuristics: the lines without a match
+// on the other side are set aside before the diff, and the search for the
+// shortest edit script gives up on costly paths. The counts are not always
+// minimal, but they match those of git log --numstat
+func countLineChanges(a, b []string) (additions, deletions int) {
+	oldSide, newSide := newLineDiff(a, b)
+	diff := &lineDiff{old: oldSide, new: newSide}
+	diff.compare(0, len(oldSide.classes), 0, len(newSide.classes), false)
+	return newSide.changed(), oldSide.changed()
+}
+
+const (
+	diffMaxCostMin  = 256
+	diffHeurMinCost = 256
+	diffSnakeCount  = 20
+	diffHeurFactor  = 4
+	diffMaxEqLimit  = 1024
+	diffScanWindow  = 100
+	diffDiscardRun  = 4
+	diffLineMax     = int(^uint(0) >> 1)
+)
+
+// diffSide is one of the files compared: the class of each line, equal lines
+// sharing a class, and the lines kept for the diff among them
+type diffSide struct {
+	changes []bool
+	classes []int
+	index   []int
+}
+
+func (s *diffSide) changed() int {
+	count := 0
+	for _, changed := range s.changes {
+		if changed {
+			count++
+		}
+	}
+	return count
+}
+
+// diffSqrt is the rough square root of xdiff, a power of two
+func diffSqrt(n int) int {
+	i := 1
+	for ; n > 0; n >>= 2 {
+		i <<= 1
+	}
+	return i
+}
+
+// newLineDiff classifies the lines, trims the common ends and sets aside the
+// lines which can't be part of the common subsequence
+func newLineDiff(a, b []string) (*diffSide, *diffSide) {
+	classes := make(map[string]int)
+	var counts [][2]int
+	classify := func(lines []string, side int) []int {
+		result := make([]int, len(lines))
+		for i, line := range lines {
+			class, exists := classes[line]
+			if !exists {
+				class = len(counts)
+				classes[line] = class
+				counts = append(counts, [2]int{})
+			}
+			counts[class][side]++
+			result[i] = class
+		}
+		return result
+	}
+	aClasses, bClasses := classify(a, 0), classify(b, 1)
+
+	start := 0
+	for start < len(a) && start < len(b) && aClasses[start] == bClasses[start] {
+		start++
+	}
+	suffix := 0
+	for suffix < len(a)-start && suffix < len(b)-start && aClasses[len(a)-1-suffix] == bClasses[len(b)-1-suffix] {
+		suffix++
+	}
+
+	sides := [2]*diffSide{{changes: make([]bool, len(a))}, {changes: make([]bool, len(b))}}
+	lines := [2][]int{aClasses, bClasses}
+	var discards [2][]byte
+	for side := range sides {
+		end := len(lines[side]) - suffix
+		limit := diffSqrt(len(lines[side]))
+		if limit > diffMaxEqLimit {
+			limit = diffMaxEqLimit
+		}
+		discards[side] = make([]byte, len(lines[side]))
+		for i := start; i < end; i++ {
+			// the matches are counted on the other side
+			switch matches := counts[lines[side][i]][1-side]; {
+			case matches == 0:
+				discards[side][i] = 0
+			case matches >= limit:
+				discards[side][i] = 2
+			default:
+				discards[side][i] = 1
+			}
+		}
+	}
+	for side, s := range sides {
+		end := len(lines[side]) - suffix
+		for i := start; i < end; i++ {
+			if discard := discards[side][i]; discard == 1 || discard == 2 && !discardMultiple(discards[side], i, start, end-1) {
+				s.index = append(s.index, i)
+				s.classes = append(s.classes, lines[side][i])
+			} else {
+				s.changes[i] = true
+			}
+		}
+	}
+	return sides[0], sides[1]
+}
+
+// discardMultiple tells whether a line with many matches is set aside, which
+// happens when it lies among lines without a match, like xdl_clean_mmatch
+func discardMultiple(discards []byte, i, start, end int) bool {
+	if i-start > diffScanWindow {
+		start = i - diffScanWindow
+	}
+	if end-i > diffScanWindow {
+		end = i + diffScanWindow
+	}
+	before, multipleBefore := 0, 1
+	for r := 1; i-r >= start; r++ {
+		if discards[i-r] == 0 {
+			before++
+		} else if discards[i-r] == 2 {
+			multipleBefore++
+		} else {
+			break
+		}
+	}
+	if before == 0 {
+		return false
+	}
+	after, multipleAfter := 0, 1
+	for r := 1; i+r <= end; r++ {
+		if discards[i+r] == 0 {
+			after++
+		} else if discards[i+r] == 2 {
+			multipleAfter++
+		} else {
+			break
+		}
+	}
+	if after == 0 {
+		return false
+	}
+	unmatched, multiple := before+after, multipleBefore+multipleAfter
+	return multiple*diffDiscardRun < multiple+unmatched
+}
+
+// lineDiff holds the furthest reaching paths of the forward and backward
+// searches, indexed by diagonal
+type lineDiff struct {
+	old, new *diffSide
+	forward  []int
+	backward []int
+	diagonal int
+	maxCost  int
+}
+
+type diffSplit struct {
+	i1, i2          int
+	minLow, minHigh bool
+}
+
+// compare marks the changed lines of the boxes [off1, lim1) x [off2, lim2),
+// splitting them in the middle of their edit script
+func (d *lineDiff) compare(off1, lim1, off2, lim2 int, needMin bool) {
+	if d.forward == nil {
+		size := len(d.old.classes) + len(d.new.classes) + 3
+		d.forward = make([]int, size)
+		d.backward = make([]int, size)
+		d.diagonal = len(d.new.classes) + 1
+		d.maxCost = diffSqrt(size)
+		if d.maxCost < diffMaxCostMin {
+			d.maxCost = diffMaxCostMin
+		}
+	}
+	a, b := d.old.classes, d.new.classes
+	for off1 < lim1 && off2 < lim2 && a[off1] == b[off2] {
+		off1++
+		off2++
+	}
+	for off1 < lim1 && off2 < lim2 && a[lim1-1] == b[lim2-1] {
+		lim1--
+		lim2--
+	}
+	switch {
+	case off1 == lim1:
+		for ; off2 < lim2; off2++ {
+			d.new.changes[d.new.index[off2]] = true
+		}
+	case off2 == lim2:
+		for ; off1 < lim1; off1++ {
+			d.old.changes[d.old.index[off1]] = true
+		}
+	default:
+		split := d.split(off1, lim1, off2, lim2, needMin)
+		d.compare(off1, split.i1, off2, split.i2, split.minLow)
+		d.compare(split.i1, lim1, split.i2, lim2, split.minHigh)
+	}
+}
+
+// split finds the middle of the edit script of a box, or a good enough point
+// when the search gets costly, like xdl_split
+func (d *lineDiff) split(off1, lim1, off2, lim2 int, needMin bool) diffSplit {
+	a, b := d.old.classes, d.new.classes
+	kf := func(k int) *int { return &d.forward[d.diagonal+k] }
+	kb := func(k int) *int { return &d.backward[d.diagonal+k] }
+	dmin, dmax := off1-lim2, lim1-off2
+	fmid, bmid := off1-off2, lim1-lim2
+	odd := (fmid-bmid)&1 != 0
+	fmin, fmax := fmid, fmid
+	bmin, bmax := bmid, bmid
+	*kf(fmid) = off1
+	*kb(bmid) = lim1
+
+	for ec := 1; ; ec++ {
+		gotSnake := false
+		if fmin > dmin {
+			fmin--
+			*kf(fmin - 1) = -1
+		} else {
+			fmin++
+		}
+		if fmax < dmax {
+			fmax++
+			*kf(fmax + 1) = -1
+		} else {
+			fmax--
+		}
+		for k := fmax; k >= fmin; k -= 2 {
+			var i1 int
+			if *kf(k - 1) >= *kf(k + 1) {
+				i1 = *kf(k - 1) + 1
+			} else {
+				i1 = *kf(k + 1)
+			}
+			prev := i1
+			i2 := i1 - k
+			for i1 < lim1 && i2 < lim2 && a[i1] == b[i2] {
+				i1++
+				i2++
+			}
+			if i1-prev > diffSnakeCount {
+				gotSnake = true
+			}
+			*kf(k) = i1
+			if odd && bmin <= k && k <= bmax && *kb(k) <= i1 {
+				return diffSplit{i1, i2, true, true}
+			}
+		}
+
+		if bmin > dmin {
+			bmin--
+			*kb(bmin - 1) = diffLineMax
+		} else {
+			bmin++
+		}
+		if bmax < dmax {
+			bmax++
+			*kb(bmax + 1) = diffLineMax
+		} else {
+			bmax--
+		}
+		for k := bmax; k >= bmin; k -= 2 {
+			var i1 int
+			if *kb(k - 1) < *kb(k + 1) {
+				i1 = *kb(k - 1)
+			} else {
+				i1 = *kb(k + 1) - 1
+			}
+			prev := i1
+			i2 := i1 - k
+			for i1 > off1 && i2 > off2 && a[i1-1] == b[i2-1] {
+				i1--
+				i2--
+			}
+			if prev-i1 > diffSnakeCount {
+				gotSnake = true
+			}
+			*kb(k) = i1
+			if !odd && fmin <= k && k <= fmax && i1 <= *kf(k) {
+				return diffSplit{i1, i2, true, true}
+			}
+		}
+
+		if needMin {
+			continue
+		}
+
+		// past some cost, a diagonal far from the corner ending with a long
+		// snake is a good enough split
+		if gotSnake && ec > diffHeurMinCost {
+			best, split := 0, diffSplit{minLow: true}
+			for k := fmax; k >= fmin; k -= 2 {
+				dd := k - fmid
+				if dd < 0 {
+					dd = -dd
+				}
+				i1 := *kf(k)
+				i2 := i1 - k
+				v := (i1 - off1) + (i2 - off2) - dd
+				if v > diffHeurFactor*ec && v > best && off1+diffSnakeCount <= i1 && i1 < lim1 && off2+diffSnakeCount <= i2 && i2 < lim2 {
+					for n := 1; a[i1-n] == b[i2-n]; n++ {
+						if n == diffSnakeCount {
+							best, split.i1, split.i2 = v, i1, i2
+							break
+						}
+					}
+				}
+			}
+			if best > 0 {
+				return split
+			}
+			best, split = 0, diffSplit{minHigh: true}
+			for k := bmax; k >= bmin; k -= 2 {
+				dd := k - bmid
+				if dd < 0 {
+					dd = -dd
+				}
+				i1 := *kb(k)
+				i2 := i1 - k
+				v := (lim1 - i1) + (lim2 - i2) - dd
+				if v > diffHeurFactor*ec && v > best && off1 < i1 && i1 <= lim1-diffSnakeCount && off2 < i2 && i2 <= lim2-diffSnakeCount {
+					for n := 0; a[i1+n] == b[i2+n]; n++ {
+						if n == diffSnakeCount-1 {
+							best, split.i1, split.i2 = v, i1, i2
+							break
+						}
+					}
+				}
+			}
+			if best > 0 {
+				return split
+			}
+		}
+
+		// enough is enough, the furthest reaching path is taken
+		if ec >= d.maxCost {
+			fbest, fbest1 := -1, -1
+			for k := fmax; k >= fmin; k -= 2 {
+				i1 := *kf(k)
+				if i1 > lim1 {
+					i1 = lim1
+				}
+				i2 := i1 - k
+				if lim2 < i2 {
+					i1, i2 = lim2+k, lim2
+				}
+				if fbest < i1+i2 {
+					fbest, fbest1 = i1+i2, i1
+				}
+			}
+			bbest, bbest1 := diffLineMax, diffLineMax
+			for k := bmax; k >= bmin; k -= 2 {
+				i1 := *kb(k)
+				if i1 < off1 {
+					i1 = off1
+				}
+				i2 := i1 - k
+				if i2 < off2 {
+					i1, i2 = off2+k, off2
+				}
+				if i1+i2 < bbest {
+					bbest, bbest1 = i1+i2, i1
+				}
+			}
+			if (lim1+lim2)-bbest < fbest-(off1+off2) {
+				return diffSplit{fbest1, fbest - fbest1, true, false}
+			}
+			return diffSplit{bbest1, bbest - bbest1, false, true}
+		}
+	}
+}
+
+// selectCommits resolves the revisions into the heads to walk from, the
+// commits to hide and a filter on the commit dates
+func (s *ObjectStore) selectCommits(revs Revisions) ([]string, map[string]bool, func(*Commit) bool, error) {
+	tip, err := s.ResolveRevision(revs.Tip())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	hidden := make(map[string]bool)
+	if revs.Range != "" {
+		start, _ := revs.SplitRange()
+		base, err := s.ResolveRevision(start)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		// like git, the excluded commits are those of the whole history of the start
+		err = s.WalkCommits([]string{base}, nil, false, func(commit *Commit) error {
+			hidden[commit.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	var since, until time.Time
+	if revs.Since != "" {
+		if since, err = parseDate(revs.Since); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if revs.Until != "" {
+		if until, err = parseDate(revs.Until); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	inWindow := func(commit *Commit) bool {
+		if !since.IsZero() && commit.Committer.When.Before(since) {
+			return false
+		}
+		return until.IsZero() || !commit.Committer.When.After(until)
+	}
+	heads := []string{tip}
+	for _, ref := range revs.Refs {
+		head, err := s.ResolveRevision(ref)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		heads = append(heads, head)
+	}
+	return heads, hidden, inWindow, nil
+}
+
+// coAuthorTrailers returns the Co-authored-by values of the trailer block of
+// a message, its last paragraph when git recognises it as trailers: only
+// made of trailers, or at least a quarter of trailers with a Signed-off-by
+func coAuthorTrailers(message string) []string {
+	paragraphs := strings.Split(strings.TrimRight(message, " \n"), "\n\n")
+	if len(paragraphs) < 2 {
+		return nil
+	}
+	var values []string
+	trailers, others := 0, 0
+	recognised, previousCoAuthor := false, false
+	for _, line := range strings.Split(paragraphs[len(paragraphs)-1], "\n") {
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			if previousCoAuthor {
+				values[len(values)-1] += " " + strings.TrimSpace(line)
+			}
+			continue
+		}
+		previousCoAuthor = false
+		colon := strings.IndexByte(line, ':')
+		if colon <= 0 || strings.ContainsAny(strings.TrimRight(line[:colon], " "), " \t") {
+			others++
+			if strings.HasPrefix(line, "(cherry picked from commit ") {
+				recognised = true
+			}
+			continue
+		}
+		trailers++
+		key := strings.TrimRight(line[:colon], " ")
+		if strings.EqualFold(key, "Signed-off-by") {
+			recognised = true
+		}
+		if strings.EqualFold(key, "Co-authored-by") {
+			values = append(values, strings.TrimSpace(line[colon+1:]))
+			previousCoAuthor = true
+		}
+	}
+	if trailers == 0 || (others > 0 && !(recognised && trailers*3 >= others)) {
+		return nil
+	}
+	return values
+}
+
+// writeNativeCommit writes the header and numstat lines of a commit in the
+// format of ExecGitHistory
+func (s *ObjectStore) writeNativeCommit(commit *Commit, diff DiffOptions, out io.Writer) error {
+	coAuthors := strings.Join(coAuthorTrailers(commit.Message), coAuthorSeparator)
+	if _, err := fmt.Fprintf(out, "%v|%v|%v|%v|%v|%v|%v|%v|%v\n", commit.Author.Name, commit.Author.When.Format(gitDateLayout), coAuthors, commit.Author.Email,
+		commit.Committer.Name, commit.Committer.Email, commit.Committer.When.Format(gitDateLayout), commit.Hash, strings.Join(commit.Parents, " ")); err != nil {
+		return err
+	}
+	// merges have no numstat unless they are diffed against their first parent
+	if len(commit.Parents) > 1 && !diff.MergeDiffs {
+		_, err := fmt.Fprintln(out)
+		return err
+	}
+	parentTree := ""
+	if len(commit.Parents) > 0 {
+		parent, err := s.ReadCommit(commit.Parents[0])
+		if err != nil {
+			return err
+		}
+		parentTree = parent.Tree
+	}
+	var changes []fileChange
+	if err := s.DiffTrees(parentTree, commit.Tree, "", &changes); err != nil {
+		return err
+	}
+	changes, err := s.DetectRenames(changes)
+	if err != nil {
+		return err
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	var lines strings.Builder
+	lines.WriteString("\n")
+	for _, change := range changes {
+		writeRawLine(&lines, change)
+	}
+	for _, change := range changes {
+		additions, deletions, binary, err := s.Numstat(change, diff.HistoryWhitespace)
+		if err != nil {
+			return err
+		}
+		// git diff -w leaves out the files whose content only changed in whitespace
+		if diff.HistoryWhitespace && !binary && additions == 0 && deletions == 0 && change.OldPath == "" && change.OldMode == change.NewMode && change.OldHash != change.NewHash {
+			continue
+		}
+		path := change.Path
+		if change.OldPath != "" {
+			path = formatRenamePath(change.OldPath, change.Path)
+		}
+		if binary {
+			fmt.Fprintf(&lines, "-\t-\t%v\n", path)
+		} else {
+			fmt.Fprintf(&lines, "%v\t%v\t%v\n", additions, deletions, path)
+		}
+	}
+	_, err = io.WriteString(out, lines.String())
+	return err
+}
+
+// writeRawLine writes the change like git log --raw --no-abbrev, the
+// similarity of renames apart
+func writeRawLine(out io.Writer, change fileChange) {
+	oldHash, newHash, status := change.OldHash, change.NewHash, "M"
+	switch {
+	case change.OldPath != "":
+		status = "R"
+	case oldHash == "":
+		oldHash, status = nullHash, "A"
+	case newHash == "":
+		newHash, status = nullHash, "D"
+	}
+	if change.OldPath != "" {
+		fmt.Fprintf(out, ":%06o %06o %v %v %v\t%v\t%v\n", change.OldMode, change.NewMode, oldHash, newHash, status, change.OldPath, change.Path)
+	} else {
+		fmt.Fprintf(out, ":%06o %06o %v %v %v\t%v\n", change.OldMode, change.NewMode, oldHash, newHash, status, change.Path)
+	}
+}
+
+// WriteNativeHistory writes the selected history in the format of
+// ExecGitHistory, the commits found in the cache are not diffed again. The
+// cache may be nil.
+func WriteNativeHistory(repo string, revs Revisions, diff DiffOptions, out io.Writer, cache *HistoryCache) error {
+	store, err := OpenObjectStore(repo)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	heads, hidden, inWindow, err := store.selectCommits(revs)
+	if err != nil {
+		return err
+	}
+	return store.WalkCommits(heads, hidden, revs.FirstParent, func(commit *Commit) error {
+		if !inWindow(commit) {
+			return nil
+		}
+		if cache == nil {
+			return store.writeNativeCommit(commit, diff, out)
+		}
+		block, cached, err := cache.Get(commit.Hash)
+		if err != nil {
+			return err
+		}
+		if !cached {
+			var lines strings.Builder
+			if err := store.writeNativeCommit(commit, diff, &lines); err != nil {
+				return err
+			}
+			block = lines.String()
+			if err := cache.Put(commit.Hash, block); err != nil {
+				return err
+			}
+		}
+		_, err = io.WriteString(out, block)
+		return err
+	})
+}
+
+// ReadGitHistory is the pure Go counterpart of ExecGitHistory
+func ReadGitHistory(repo string, revs Revisions, diff DiffOptions, cache *HistoryCache) (io.ReadCloser, error) {
+	fmt.Println("Reading the stats in the repo (1/3)", repo)
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(WriteNativeHistory(repo, revs, diff, writer, cache))
+	}()
+	return reader, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestCountLineChanges(t *testing.T) {
+	a := splitLines([]byte("one\ntwo\nthree\nfour\n"))
+	b := splitLines([]byte("one\n2\nthree\nfour\nfive\n"))
+	additions, deletions := countLineChanges(a, b)
+	if additions != 2 || deletions != 1 {
+		t.Errorf("Expected 2 additions and 1 deletion, got %v and %v", additions, deletions)
+	}
+
+	additions, deletions = countLineChanges(nil, b)
+	if additions != 5 || deletions != 0 {
+		t.Errorf("A new file should only have additions, got %v and %v", additions, deletions)
+	}
+
+	// the missing newline at the end of the file is a change of its own
+	a = splitLines([]byte("one\ntwo"))
+	b = splitLines([]byte("one\ntwo\n"))
+	additions, deletions = countLineChanges(a, b)
+	if additions != 1 || deletions != 1 {
+		t.Errorf("Expected 1 addition and 1 deletion, got %v and %v", additions, deletions)
+	}
+}
+
+func TestApplyDelta(t *testing.T) {
+	base := []byte("hello world")
+	// base size 11, target size 11, copy 6 bytes from 0, insert "there"
+	delta := []byte{11, 11, 0x90, 6, 5, 't', 'h', 'e', 'r', 'e'}
+	target, err := applyDelta(base, delta)
+	if err != nil {
+		t.Errorf("Applying a valid delta should not fail: %v", err)
+	}
+	if string(target) != "hello there" {
+		t.Errorf("The expected target was 'hello there' and we got '%v'", string(target))
+	}
+
+	if _, err := applyDelta([]byte("short"), delta); err == nil {
+		t.Errorf("A delta applied on the wrong base should fail")
+	}
+}
+
+func TestParseSignature(t *testing.T) {
+	sig := parseSignature("Contributor One <one@example.com> 1464638933 +0200")
+	if sig.Name != "Contributor One" || sig.Email != "one@example.com" {
+		t.Errorf("Unexpected signature %v <%v>", sig.Name, sig.Email)
+	}
+	if sig.When.Format(gitDateLayout) != "Mon May 30 22:08:53 2016 +0200" {
+		t.Errorf("Unexpected date %v", sig.When.Format(gitDateLayout))
+	}
+}
+
+func TestCoAuthorTrailers(t *testing.T) {
+	values := coAuthorTrailers("Pair on the parser\n\nCo-authored-by: One <one@example.com>\nco-authored-by: Two\n  Continued <two@example.com>\n")
+	if len(values) != 2 || values[0] != "One <one@example.com>" || values[1] != "Two Continued <two@example.com>" {
+		t.Errorf("Unexpected co-authors %v", values)
+	}
+	if values := coAuthorTrailers("Co-authored-by: One <one@example.com>\n"); values != nil {
+		t.Errorf("The subject can't hold trailers, got %v", values)
+	}
+	if values := coAuthorTrailers("Subject\n\nSome text\nCo-authored-by: One <one@example.com>\n"); values != nil {
+		t.Errorf("A paragraph of text is not a trailer block, got %v", values)
+	}
+	if values := coAuthorTrailers("Subject\n\nSome text\nCo-authored-by: One <one@example.com>\nSigned-off-by: Two <two@example.com>\n"); len(values) != 1 {
+		t.Errorf("A Signed-off-by makes the block a trailer block, got %v", values)
+	}
+}
+
+// readHistories returns the history of a repository read by the built-in
+// reader and by git log, the quotes git adds around the headers apart
+func readHistories(t *testing.T, repo string) (string, string) {
+	native, err := ReadGitHistory(repo, Revisions{}, DiffOptions{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nativeLog, err := ioutil.ReadAll(native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	git, err := ExecGitHistory(repo, Revisions{}, DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gitLog, _ := ioutil.ReadAll(git)
+	if err := git.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return string(nativeLog), strings.Replace(string(gitLog), "'", "", -1)
+}
+
+func TestNativeHistory(t *testing.T) {
+	// git sets aside the braces among lines without a match, so that its
+	// counts are not minimal
+	var long, rewritten, before, after strings.Builder
+	for i := 0; i < 5; i++ {
+		fmt.Fprintf(&before, "a%d\nb%d\nc%d\nd%d\n}\n", i, i, i, i)
+		fmt.Fprintf(&after, "e%d\nf%d\ng%d\nh%d\n}\n", i, i, i, i)
+	}
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(&long, "line %d\n}\n", i)
+		if i%7 == 0 {
+			fmt.Fprintf(&rewritten, "changed %d\n", i)
+		} else {
+			fmt.Fprintf(&rewritten, "line %d\n}\n", i)
+		}
+	}
+	repo := newTestRepo(t, []testCommit{
+		{Author: "Alice", Date: "2016-05-30T10:00:00", Files: map[string]string{"src/main.c": "int main() {\n\treturn 0;\n}\n", "src/long.c": long.String(), "src/blocks.c": long.String() + before.String(), "logo.png": "\x89PNG\x00\x01"}},
+		{Author: "Bob", Date: "2016-05-31T10:00:00", Files: map[string]string{"src/main.c": "int main() {\n\tputs(\"hi\");\n\treturn 0;\n}", "src/long.c": rewritten.String(), "src/blocks.c": long.String() + after.String(), "logo.png": "\x89PNG\x00\x02"}},
+		{Author: "Carol", Date: "2016-06-01T10:00:00", Files: map[string]string{"src/long.c": "", "Readme.md": "# Test\n"}},
+	})
+	native, git := readHistories(t, repo)
+	if native != git {
+		t.Errorf("The loose objects should give the history of git log\nnative:\n%v\ngit:\n%v", native, git)
+	}
+
+	if out, err := exec.Command("git", "-C", repo, "gc", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git gc: %v %s", err, out)
+	}
+	native, git = readHistories(t, repo)
+	if native != git {
+		t.Errorf("The packfile should give the history of git log\nnative:\n%v\ngit:\n%v", native, git)
+	}
+}
+
+func TestNativeShallowHistory(t *testing.T) {
+	origin := newTestRepo(t, []testCommit{
+		{Author: "Alice", Date: "2016-05-30T10:00:00", Files: map[string]string{"main.c": "int a;\n"}},
+		{Author: "Bob", Date: "2016-05-31T10:00:00", Files: map[string]string{"main.c": "int a;\nint b;\n"}},
+		{Author: "Carol", Date: "2016-06-01T10:00:00", Files: map[string]string{"main.c": "int b;\n"}},
+	})
+	repo := t.TempDir()
+	if out, err := exec.Command("git", "clone", "-q", "--depth", "2", "file://"+origin, repo).CombinedOutput(); err != nil {
+		t.Fatalf("git clone: %v %s", err, out)
+	}
+	native, git := readHistories(t, repo)
+	if native != git {
+		t.Errorf("The shallow clone should give the history of git log\nnative:\n%v\ngit:\n%v", native, git)
+	}
+}
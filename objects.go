@@ -0,0 +1,794 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Object types, as stored in pack files
+
+const (
+	objCommit   = 1
+	objTree     = 2
+	objBlob     = 3
+	objTag      = 4
+	objOfsDelta = 6
+	objRefDelta = 7
+)
+
+var objTypeNames = map[string]int{"commit": objCommit, "tree": objTree, "blob": objBlob, "tag": objTag}
+
+type packIndex struct {
+	names   []byte // sorted 20 byte object names
+	offsets []int64
+}
+
+type packFile struct {
+	path  string
+	file  *os.File
+	index packIndex
+}
+
+type cachedObject struct {
+	kind int
+	data []byte
+}
+
+// ObjectStore reads loose and packed objects straight from a .git directory
+type ObjectStore struct {
+	GitDir     string
+	objectDirs []string
+	packs      []*packFile
+	baseCache  map[string]cachedObject
+	cacheSize  int
+	shallow    map[string]bool // the commits of a shallow clone with their parents cut
+}
+
+const maxBaseCacheSize = 64 << 20 // bytes
+
+// FindGitDir returns the git directory of a work tree, following .git files
+// and accepting bare repositories as they are
+func FindGitDir(repo string) (string, error) {
+	dotGit := filepath.Join(repo, ".git")
+	info, err := os.Stat(dotGit)
+	if err == nil && info.IsDir() {
+		return dotGit, nil
+	}
+	if err == nil {
+		content, err := ioutil.ReadFile(dotGit)
+		if err != nil {
+			return "", err
+		}
+		line := strings.TrimSpace(string(content))
+		if !strings.HasPrefix(line, "gitdir:") {
+			return "", fmt.Errorf("invalid .git file in %v", repo)
+		}
+		gitDir := strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+		if !filepath.IsAbs(gitDir) {
+			gitDir = filepath.Join(repo, gitDir)
+		}
+		return gitDir, nil
+	}
+	if _, err := os.Stat(filepath.Join(repo, "objects")); err == nil {
+		return repo, nil
+	}
+	return "", fmt.Errorf("%v is not a git repository", repo)
+}
+
+func OpenObjectStore(repo string) (*ObjectStore, error) {
+	gitDir, err := FindGitDir(repo)
+	if err != nil {
+		return nil, err
+	}
+	store := &ObjectStore{GitDir: gitDir, baseCache: make(map[string]cachedObject)}
+	// worktrees keep their objects in the common directory
+	if common, err := ioutil.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir := strings.TrimSpace(string(common))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+		store.objectDirs = append(store.objectDirs, filepath.Join(commonDir, "objects"))
+	} else {
+		store.objectDirs = append(store.objectDirs, filepath.Join(gitDir, "objects"))
+	}
+	alternates, err := ioutil.ReadFile(filepath.Join(store.objectDirs[0], "info", "alternates"))
+	if err == nil {
+		for _, line := range strings.Split(string(alternates), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if !filepath.IsAbs(line) {
+				line = filepath.Join(store.objectDirs[0], line)
+			}
+			store.objectDirs = append(store.objectDirs, line)
+		}
+	}
+	store.shallow = readShallow(store.commonDir())
+	for _, dir := range store.objectDirs {
+		indexes, _ := filepath.Glob(filepath.Join(dir, "pack", "*.idx"))
+		for _, idx := range indexes {
+			pack, err := openPackFile(idx)
+			if err != nil {
+				store.Close()
+				return nil, err
+			}
+			store.packs = append(store.packs, pack)
+		}
+	}
+	return store, nil
+}
+
+// readShallow lists the commits whose parents a shallow clone left out
+func readShallow(gitDir string) map[string]bool {
+	shallow := make(map[string]bool)
+	content, err := ioutil.ReadFile(filepath.Join(gitDir, "shallow"))
+	if err != nil {
+		return shallow
+	}
+	for _, line := range strings.Fields(string(content)) {
+		shallow[line] = true
+	}
+	return shallow
+}
+
+func (s *ObjectStore) Close() {
+	for _, pack := range s.packs {
+		pack.file.Close()
+	}
+	s.packs = nil
+}
+
+// Pack indexes
+
+func readPackIndex(path string) (packIndex, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return packIndex{}, err
+	}
+	var index packIndex
+	if len(content) >= 8 && bytes.Equal(content[:4], []byte{0xff, 't', 'O', 'c'}) {
+		if binary.BigEndian.Uint32(content[4:8]) != 2 {
+			return index, fmt.Errorf("unsupported pack index version in %v", path)
+		}
+		if len(content) < 8+256*4 {
+			return index, fmt.Errorf("truncated pack index %v", path)
+		}
+		count := int(binary.BigEndian.Uint32(content[8+255*4:]))
+		namesStart := 8 + 256*4
+		offsetsStart := namesStart + count*20 + count*4
+		largeStart := offsetsStart + count*4
+		if len(content) < largeStart {
+			return index, fmt.Errorf("truncated pack index %v", path)
+		}
+		index.names = content[namesStart : namesStart+count*20]
+		index.offsets = make([]int64, count)
+		for i := 0; i < count; i++ {
+			offset := binary.BigEndian.Uint32(content[offsetsStart+i*4:])
+			if offset&0x80000000 != 0 {
+				large := largeStart + int(offset&0x7fffffff)*8
+				if len(content) < large+8 {
+					return index, fmt.Errorf("truncated pack index %v", path)
+				}
+				index.offsets[i] = int64(binary.BigEndian.Uint64(content[large:]))
+			} else {
+				index.offsets[i] = int64(offset)
+			}
+		}
+		return index, nil
+	}
+	// version 1: fan-out table followed by (offset, name) pairs
+	if len(content) < 256*4 {
+		return index, fmt.Errorf("truncated pack index %v", path)
+	}
+	count := int(binary.BigEndian.Uint32(content[255*4:]))
+	if len(content) < 256*4+count*24 {
+		return index, fmt.Errorf("truncated pack index %v", path)
+	}
+	index.names = make([]byte, 0, count*20)
+	index.offsets = make([]int64, count)
+	for i := 0; i < count; i++ {
+		entry := content[256*4+i*24:]
+		index.offsets[i] = int64(binary.BigEndian.Uint32(entry))
+		index.names = append(index.names, entry[4:24]...)
+	}
+	return index, nil
+}
+
+func (idx *packIndex) find(name []byte) (int64, bool) {
+	count := len(idx.offsets)
+	i := sort.Search(count, func(i int) bool {
+		return bytes.Compare(idx.names[i*20:i*20+20], name) >= 0
+	})
+	if i < count && bytes.Equal(idx.names[i*20:i*20+20], name) {
+		return idx.offsets[i], true
+	}
+	return 0, false
+}
+
+func openPackFile(indexPath string) (*packFile, error) {
+	index, err := readPackIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	path := strings.TrimSuffix(indexPath, ".idx") + ".pack"
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &packFile{path: path, file: file, index: index}, nil
+}
+
+// Objects
+
+func (s *ObjectStore) ReadObject(hash string) (int, []byte, error) {
+	name, err := hex.DecodeString(hash)
+	if err != nil || len(name) != 20 {
+		return 0, nil, fmt.Errorf("invalid object name %v", hash)
+	}
+	for _, pack := range s.packs {
+		if offset, ok := pack.index.find(name); ok {
+			return s.readPacked(pack, offset)
+		}
+	}
+	for _, dir := range s.objectDirs {
+		kind, data, err := readLooseObject(filepath.Join(dir, hash[:2], hash[2:]))
+		if err == nil {
+			return kind, data, nil
+		}
+		if !os.IsNotExist(err) {
+			return 0, nil, err
+		}
+	}
+	return 0, nil, fmt.Errorf("object %v not found", hash)
+}
+
+func (s *ObjectStore) HasObject(hash string) bool {
+	name, err := hex.DecodeString(hash)
+	if err != nil || len(name) != 20 {
+		return false
+	}
+	for _, pack := range s.packs {
+		if _, ok := pack.index.find(name); ok {
+			return true
+		}
+	}
+	for _, dir := range s.objectDirs {
+		if _, err := os.Stat(filepath.Join(dir, hash[:2], hash[2:])); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// minAbbrev is the shortest abbreviated hash accepted, as in git
+const minAbbrev = 4
+
+// ExpandHash returns the object whose hash starts with an abbreviation,
+// looking in the pack indexes and the loose fan-out directories
+func (s *ObjectStore) ExpandHash(abbrev string) (string, error) {
+	abbrev = strings.ToLower(abbrev)
+	if len(abbrev) < minAbbrev || len(abbrev) > 40 {
+		return "", fmt.Errorf("invalid abbreviated hash %v", abbrev)
+	}
+	low, err := hex.DecodeString(abbrev + strings.Repeat("0", 40-len(abbrev)))
+	if err != nil {
+		return "", fmt.Errorf("invalid abbreviated hash %v", abbrev)
+	}
+	found := make(map[string]bool)
+	for _, pack := range s.packs {
+		names := pack.index.names
+		count := len(pack.index.offsets)
+		i := sort.Search(count, func(i int) bool {
+			return bytes.Compare(names[i*20:i*20+20], low) >= 0
+		})
+		for ; i < count; i++ {
+			hash := hex.EncodeToString(names[i*20 : i*20+20])
+			if !strings.HasPrefix(hash, abbrev) {
+				break
+			}
+			found[hash] = true
+		}
+	}
+	for _, dir := range s.objectDirs {
+		entries, _ := ioutil.ReadDir(filepath.Join(dir, abbrev[:2]))
+		for _, entry := range entries {
+			if hash := abbrev[:2] + entry.Name(); isHash(hash) && strings.HasPrefix(hash, abbrev) {
+				found[hash] = true
+			}
+		}
+	}
+	if len(found) > 1 {
+		return "", fmt.Errorf("short hash %v is ambiguous", abbrev)
+	}
+	for hash := range found {
+		return hash, nil
+	}
+	return "", fmt.Errorf("object %v not found", abbrev)
+}
+
+func readLooseObject(path string) (int, []byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.Close()
+	reader, err := zlib.NewReader(file)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer reader.Close()
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	nul := bytes.IndexByte(content, 0)
+	if nul < 0 {
+		return 0, nil, fmt.Errorf("corrupt loose object %v", path)
+	}
+	header := strings.SplitN(string(content[:nul]), " ", 2)
+	kind, ok := objTypeNames[header[0]]
+	if !ok || len(header) != 2 {
+		return 0, nil, fmt.Errorf("corrupt loose object %v", path)
+	}
+	size, err := strconv.Atoi(header[1])
+	if err != nil || size != len(content)-nul-1 {
+		return 0, nil, fmt.Errorf("corrupt loose object %v", path)
+	}
+	return kind, content[nul+1:], nil
+}
+
+func (s *ObjectStore) readPacked(pack *packFile, offset int64) (int, []byte, error) {
+	key := fmt.Sprintf("%v@%v", pack.path, offset)
+	if cached, ok := s.baseCache[key]; ok {
+		return cached.kind, cached.data, nil
+	}
+	reader := bufio.NewReader(io.NewSectionReader(pack.file, offset, 1<<62))
+	header, err := reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	kind := int(header>>4) & 7
+	size := uint64(header & 0x0f)
+	shift := uint(4)
+	headerSize := int64(1)
+	for header&0x80 != 0 {
+		header, err = reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		size |= uint64(header&0x7f) << shift
+		shift += 7
+		headerSize++
+	}
+
+	var base []byte
+	var baseKind int
+	switch kind {
+	case objCommit, objTree, objBlob, objTag:
+	case objOfsDelta:
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		distance := int64(b & 0x7f)
+		for b&0x80 != 0 {
+			b, err = reader.ReadByte()
+			if err != nil {
+				return 0, nil, err
+			}
+			distance = ((distance + 1) << 7) | int64(b&0x7f)
+		}
+		baseKind, base, err = s.readPacked(pack, offset-distance)
+		if err != nil {
+			return 0, nil, err
+		}
+	case objRefDelta:
+		name := make([]byte, 20)
+		if _, err := io.ReadFull(reader, name); err != nil {
+			return 0, nil, err
+		}
+		baseKind, base, err = s.ReadObject(hex.EncodeToString(name))
+		if err != nil {
+			return 0, nil, err
+		}
+	default:
+		return 0, nil, fmt.Errorf("unknown object type %v in %v", kind, pack.path)
+	}
+
+	inflater, err := zlib.NewReader(reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	data := make([]byte, size)
+	_, err = io.ReadFull(inflater, data)
+	inflater.Close()
+	if err != nil {
+		return 0, nil, err
+	}
+	if base != nil {
+		data, err = applyDelta(base, data)
+		if err != nil {
+			return 0, nil, fmt.Errorf("%v in %v", err, pack.path)
+		}
+		kind = baseKind
+	}
+	// delta chains share their bases, keep the recent ones around
+	if len(data) < maxBaseCacheSize/8 {
+		if s.cacheSize+len(data) > maxBaseCacheSize {
+			s.baseCache = make(map[string]cachedObject)
+			s.cacheSize = 0
+		}
+		s.baseCache[key] = cachedObject{kind: kind, data: data}
+		s.cacheSize += len(data)
+	}
+	return kind, data, nil
+}
+
+func readDeltaSize(delta []byte, pos *int) (uint64, error) {
+	var size uint64
+	shift := uint(0)
+	for {
+		if *pos >= len(delta) {
+			return 0, errors.New("truncated delta")
+		}
+		b := delta[*pos]
+		*pos++
+		size |= uint64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			return size, nil
+		}
+	}
+}
+
+func applyDelta(base, delta []byte) ([]byte, error) {
+	pos := 0
+	baseSize, err := readDeltaSize(delta, &pos)
+	if err != nil {
+		return nil, err
+	}
+	if baseSize != uint64(len(base)) {
+		return nil, errors.New("delta base size mismatch")
+	}
+	targetSize, err := readDeltaSize(delta, &pos)
+	if err != nil {
+		return nil, err
+	}
+	target := make([]byte, 0, targetSize)
+	for pos < len(delta) {
+		op := delta[pos]
+		pos++
+		if op&0x80 != 0 {
+			var offset, size uint64
+			for i := uint(0); i < 4; i++ {
+				if op&(1<<i) != 0 {
+					if pos >= len(delta) {
+						return nil, errors.New("truncated delta")
+					}
+					offset |= uint64(delta[pos]) << (8 * i)
+					pos++
+				}
+			}
+			for i := uint(0); i < 3; i++ {
+				if op&(1<<(4+i)) != 0 {
+					if pos >= len(delta) {
+						return nil, errors.New("truncated delta")
+					}
+					size |= uint64(delta[pos]) << (8 * i)
+					pos++
+				}
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > uint64(len(base)) {
+				return nil, errors.New("delta copy out of range")
+			}
+			target = append(target, base[offset:offset+size]...)
+		} else if op != 0 {
+			if pos+int(op) > len(delta) {
+				return nil, errors.New("truncated delta")
+			}
+			target = append(target, delta[pos:pos+int(op)]...)
+			pos += int(op)
+		} else {
+			return nil, errors.New("invalid delta opcode")
+		}
+	}
+	if uint64(len(target)) != targetSize {
+		return nil, errors.New("delta target size mismatch")
+	}
+	return target, nil
+}
+
+// Commits and trees
+
+type Signature struct {
+	Name  string
+	Email string
+	When  time.Time
+}
+
+type Commit struct {
+	Hash      string
+	Tree      string
+	Parents   []string
+	Author    Signature
+	Committer Signature
+	Message   string
+}
+
+type TreeEntry struct {
+	Mode uint32
+	Name string
+	Hash string
+}
+
+func (e TreeEntry) IsTree() bool {
+	return e.Mode&0170000 == 0040000
+}
+
+func (e TreeEntry) IsSubmodule() bool {
+	return e.Mode&0170000 == 0160000
+}
+
+func parseSignature(value string) Signature {
+	var sig Signature
+	open := strings.Index(value, "<")
+	closing := strings.LastIndex(value, ">")
+	if open < 0 || closing < open {
+		sig.Name = strings.TrimSpace(value)
+		return sig
+	}
+	sig.Name = strings.TrimSpace(value[:open])
+	sig.Email = value[open+1 : closing]
+	fields := strings.Fields(value[closing+1:])
+	if len(fields) >= 1 {
+		seconds, _ := strconv.ParseInt(fields[0], 10, 64)
+		location := time.UTC
+		if len(fields) >= 2 && len(fields[1]) == 5 {
+			hours, _ := strconv.Atoi(fields[1][1:3])
+			minutes, _ := strconv.Atoi(fields[1][3:5])
+			offset := hours*3600 + minutes*60
+			if fields[1][0] == '-' {
+				offset = -offset
+			}
+			location = time.FixedZone("", offset)
+		}
+		sig.When = time.Unix(seconds, 0).In(location)
+	}
+	return sig
+}
+
+func (s *ObjectStore) ReadCommit(hash string) (*Commit, error) {
+	kind, data, err := s.ReadObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	if kind != objCommit {
+		return nil, fmt.Errorf("object %v is not a commit", hash)
+	}
+	commit := &Commit{Hash: hash}
+	headers := string(data)
+	if end := strings.Index(headers, "\n\n"); end >= 0 {
+		commit.Message = headers[end+2:]
+		headers = headers[:end]
+	}
+	for _, line := range strings.Split(headers, "\n") {
+		space := strings.IndexByte(line, ' ')
+		if space < 0 {
+			continue
+		}
+		value := line[space+1:]
+		switch line[:space] {
+		case "tree":
+			commit.Tree = value
+		case "parent":
+			commit.Parents = append(commit.Parents, value)
+		case "author":
+			commit.Author = parseSignature(value)
+		case "committer":
+			commit.Committer = parseSignature(value)
+		}
+	}
+	// like git, the commits at the edge of a shallow clone have no parents
+	if s.shallow[hash] {
+		commit.Parents = nil
+	}
+	return commit, nil
+}
+
+func (s *ObjectStore) ReadTree(hash string) ([]TreeEntry, error) {
+	kind, data, err := s.ReadObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	if kind != objTree {
+		return nil, fmt.Errorf("object %v is not a tree", hash)
+	}
+	var entries []TreeEntry
+	for len(data) > 0 {
+		space := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+		if space < 0 || nul < space || len(data) < nul+21 {
+			return nil, fmt.Errorf("corrupt tree %v", hash)
+		}
+		mode, err := strconv.ParseUint(string(data[:space]), 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("corrupt tree %v", hash)
+		}
+		entries = append(entries, TreeEntry{Mode: uint32(mode), Name: string(data[space+1 : nul]), Hash: hex.EncodeToString(data[nul+1 : nul+21])})
+		data = data[nul+21:]
+	}
+	return entries, nil
+}
+
+func (s *ObjectStore) ReadBlob(hash string) ([]byte, error) {
+	kind, data, err := s.ReadObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	if kind != objBlob {
+		return nil, fmt.Errorf("object %v is not a blob", hash)
+	}
+	return data, nil
+}
+
+// Refs
+
+func (s *ObjectStore) commonDir() string {
+	return filepath.Dir(s.objectDirs[0])
+}
+
+func (s *ObjectStore) readRefFile(name string) (string, bool) {
+	for _, dir := range []string{s.GitDir, s.commonDir()} {
+		content, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err == nil {
+			return strings.TrimSpace(string(content)), true
+		}
+	}
+	return "", false
+}
+
+// PackedRefs returns the refs stored in packed-refs, with their peeled value
+// when the ref points to an annotated tag
+func (s *ObjectStore) PackedRefs() map[string]string {
+	refs := make(map[string]string)
+	content, err := ioutil.ReadFile(filepath.Join(s.commonDir(), "packed-refs"))
+	if err != nil {
+		return refs
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if line == "" || line[0] == '#' || line[0] == '^' {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) == 2 {
+			refs[fields[1]] = fields[0]
+		}
+	}
+	return refs
+}
+
+func isHash(value string) bool {
+	if len(value) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(value)
+	return err == nil
+}
+
+func (s *ObjectStore) readRef(name string, depth int) (string, bool) {
+	if depth > 8 {
+		return "", false
+	}
+	value, ok := s.readRefFile(name)
+	if !ok {
+		value, ok = s.PackedRefs()[name]
+		if !ok {
+			return "", false
+		}
+	}
+	if strings.HasPrefix(value, "ref:") {
+		return s.readRef(strings.TrimSpace(strings.TrimPrefix(value, "ref:")), depth+1)
+	}
+	return value, isHash(value)
+}
+
+// ResolveRevision turns a ref name, a hash or HEAD, optionally followed
+// by ~n and ^n ancestry suffixes, into a commit hash
+func (s *ObjectStore) ResolveRevision(rev string) (string, error) {
+	suffix := strings.IndexAny(rev, "~^")
+	if suffix < 0 {
+		return s.resolveName(rev)
+	}
+	hash, err := s.resolveName(rev[:suffix])
+	if err != nil {
+		return "", err
+	}
+	rest := rev[suffix:]
+	for rest != "" {
+		operator := rest[0]
+		rest = rest[1:]
+		digits := 0
+		for digits < len(rest) && rest[digits] >= '0' && rest[digits] <= '9' {
+			digits++
+		}
+		count := 1
+		if digits > 0 {
+			count, _ = strconv.Atoi(rest[:digits])
+			rest = rest[digits:]
+		}
+		if operator != '~' && operator != '^' {
+			return "", fmt.Errorf("unsupported revision %v", rev)
+		}
+		steps, parent := count, 0
+		if operator == '^' {
+			steps, parent = 1, count-1
+		}
+		for i := 0; i < steps; i++ {
+			commit, err := s.ReadCommit(hash)
+			if err != nil {
+				return "", err
+			}
+			if count == 0 && operator == '^' {
+				break
+			}
+			if parent >= len(commit.Parents) {
+				return "", fmt.Errorf("unknown revision %v", rev)
+			}
+			hash = commit.Parents[parent]
+		}
+	}
+	return hash, nil
+}
+
+// resolveName peels annotated tags down to the commit they point to
+func (s *ObjectStore) resolveName(rev string) (string, error) {
+	hash := ""
+	if isHash(rev) && s.HasObject(rev) {
+		hash = rev
+	} else {
+		for _, candidate := range []string{rev, "refs/" + rev, "refs/tags/" + rev, "refs/heads/" + rev, "refs/remotes/" + rev, "refs/remotes/" + rev + "/HEAD"} {
+			if value, ok := s.readRef(candidate, 0); ok {
+				hash = value
+				break
+			}
+		}
+	}
+	if hash == "" && len(rev) >= minAbbrev {
+		// like git, the refs come before the abbreviated hashes
+		hash, _ = s.ExpandHash(rev)
+	}
+	if hash == "" {
+		return "", fmt.Errorf("unknown revision %v", rev)
+	}
+	for i := 0; i < 16; i++ {
+		kind, data, err := s.ReadObject(hash)
+		if err != nil {
+			return "", err
+		}
+		if kind == objCommit {
+			return hash, nil
+		}
+		if kind != objTag || !bytes.HasPrefix(data, []byte("object ")) {
+			return "", fmt.Errorf("%v does not point to a commit", rev)
+		}
+		hash = string(data[7:47])
+	}
+	return "", fmt.Errorf("%v does not point to a commit", rev)
+}
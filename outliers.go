@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"github.com/RodolpheFouquet/termtables"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// Outlier commits, such as vendor imports, are those much larger than the
+// others of their repository: above a number of median absolute deviations
+// of the sizes, measured on their logarithm as the sizes of commits are
+// skewed, or above a number of lines
+
+const (
+	OutliersNone    = "none"
+	OutliersFlag    = "flag"    // credited as usual and listed
+	OutliersCap     = "cap"     // credited up to the threshold
+	OutliersExclude = "exclude" // left out
+)
+
+// OutlierPolicy tells which commits are outliers and what is done with them
+type OutlierPolicy struct {
+	Action     string
+	Deviations float64 // median absolute deviations, 0 to disable
+	Lines      int     // 0 to disable
+}
+
+// Outlier is a commit found too large, listed in the report
+type Outlier struct {
+	Repo      string
+	Hash      string
+	Author    string
+	Date      time.Time
+	Lines     int
+	Threshold int
+	Action    string
+}
+
+// commitSize is the number of lines changed in the files of a commit, the
+// generated ones apart
+func commitSize(files []commitFile) int {
+	size := 0
+	for _, file := range files {
+		if !file.generated {
+			size += file.Additions + file.Deletions
+		}
+	}
+	return size
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+// Threshold returns the size above which a commit is an outlier, 0 when
+// there is none. Without any spread of the sizes, the deviations tell
+// nothing.
+func (o OutlierPolicy) Threshold(sizes []int) int {
+	threshold := 0
+	if o.Deviations > 0 && len(sizes) > 0 {
+		logs := make([]float64, len(sizes))
+		for i, size := range sizes {
+			logs[i] = math.Log1p(float64(size))
+		}
+		center := median(logs)
+		deviations := make([]float64, len(logs))
+		for i, value := range logs {
+			deviations[i] = math.Abs(value - center)
+		}
+		if mad := median(deviations); mad > 0 {
+			threshold = int(math.Expm1(center + o.Deviations*mad))
+		}
+	}
+	if o.Lines > 0 && (threshold == 0 || o.Lines < threshold) {
+		threshold = o.Lines
+	}
+	return threshold
+}
+
+// MeasureHistory reads the history a first time to find the size above
+// which its commits are outliers, applied by the next ParseHistory. Only
+// the sizes are kept.
+func (p *Parser) MeasureHistory(gitOutput io.Reader) error {
+	if p.renames != nil {
+		p.FollowRenames()
+	}
+	var sizes []int
+	err := p.scanHistory(gitOutput, false, func(commit *CommitStats, contributors []string, files []commitFile) {
+		if len(commit.Parents) > 1 && p.Merges == MergesIntegrations {
+			return
+		}
+		// the commits without lines in the subtrees don't weigh
+		if size := commitSize(files); size > 0 {
+			sizes = append(sizes, size)
+		}
+	})
+	p.outlierThreshold = p.Outliers.Threshold(sizes)
+	return err
+}
+
+// creditOutlierCommit credits a commit, applying the policy when it is
+// larger than the threshold of the repository
+func (p *Parser) creditOutlierCommit(commit *CommitStats, contributors []string, files []commitFile) {
+	threshold := p.outlierThreshold
+	size := commitSize(files)
+	if p.Outliers.Action == OutliersNone || threshold == 0 || size <= threshold {
+		p.creditCommit(commit, contributors, files, 0)
+		return
+	}
+	repo := p.Prefix
+	if p.Report.current != nil {
+		repo = p.Report.current.Repo
+	}
+	p.Report.Outliers = append(p.Report.Outliers, Outlier{Repo: repo, Hash: commit.Hash, Author: contributors[0], Date: commit.Date, Lines: size, Threshold: threshold, Action: p.Outliers.Action})
+	switch p.Outliers.Action {
+	case OutliersFlag:
+		p.creditCommit(commit, contributors, files, 0)
+	case OutliersCap:
+		p.creditCommit(commit, contributors, files, threshold)
+	}
+}
+
+// PrintOutliers lists the outlier commits and what was done with them
+func PrintOutliers(report *Report) {
+	if len(report.Outliers) == 0 {
+		return
+	}
+	fmt.Println("Outlier commits")
+	table := termtables.CreateTable()
+	table.AddHeaders("Repository", "Commit", "Author", "Date", "Lines", "Threshold", "Action")
+	for _, outlier := range report.Outliers {
+		hash := outlier.Hash
+		if len(hash) > 12 {
+			hash = hash[:12]
+		}
+		table.AddRow(outlier.Repo, hash, outlier.Author, outlier.Date.Format("2006-01-02"), outlier.Lines, outlier.Threshold, outlier.Action)
+	}
+	table.SetAlign(3, 5)
+	table.SetAlign(3, 6)
+	fmt.Println(table.Render())
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestOutlierThreshold(t *testing.T) {
+	sizes := []int{10, 20, 15, 30, 12, 25, 18, 50000}
+	threshold := OutlierPolicy{Deviations: 5}.Threshold(sizes)
+	if threshold <= 50 || threshold >= 50000 {
+		t.Errorf("The vendor import should be the only outlier, threshold %v", threshold)
+	}
+	if threshold := (OutlierPolicy{Deviations: 5, Lines: 40}).Threshold(sizes); threshold != 40 {
+		t.Errorf("The lower of both thresholds should apply, got %v", threshold)
+	}
+	if threshold := (OutlierPolicy{Deviations: 5}).Threshold([]int{10, 10, 10, 500}); threshold != 0 {
+		t.Errorf("Without spread there should be no threshold, got %v", threshold)
+	}
+}
+
+func TestOutlierPolicies(t *testing.T) {
+	var log strings.Builder
+	for i, size := range []int{10, 20, 15, 30, 12, 25, 18} {
+		fmt.Fprintf(&log, "Contributor1|Mon May 30 22:08:53 2016 +0200||one@example.com|Contributor1|one@example.com|Mon May 30 22:08:53 2016 +0200|%04d\n\n%d\t0\tsrc/main.c\n", i, size)
+	}
+	log.WriteString("Contributor2|Tue May 31 22:08:53 2016 +0200||two@example.com|Contributor2|two@example.com|Tue May 31 22:08:53 2016 +0200|ffff\n\n40000\t10000\tvendor/lib.c\n")
+
+	for _, c := range []struct {
+		action    string
+		additions int
+		commits   float64
+	}{
+		{OutliersNone, 40000, 1},
+		{OutliersFlag, 40000, 1},
+		{OutliersCap, 800, 1},
+		{OutliersExclude, 0, 0},
+	} {
+		parser := NewParser("/", *NewPeriodArray(), *NewUserArray())
+		parser.Outliers = OutlierPolicy{Action: c.action, Lines: 1000}
+		parser.MeasureHistory(strings.NewReader(log.String()))
+		parser.ParseHistory(strings.NewReader(log.String()))
+		additions, commits := 0, 0.0
+		if parser.Report.HasContributor("Contributor2") {
+			additions = parser.Report.Contributors["Contributor2"].Contributions[0].Additions
+			commits = parser.Report.Contributors["Contributor2"].Contributions[0].Commits
+		}
+		if additions != c.additions || commits != c.commits {
+			t.Errorf("%v: unexpected credit of the outlier, %v additions in %v commits", c.action, additions, commits)
+		}
+		outliers := parser.Report.Outliers
+		if c.action == OutliersNone && len(outliers) != 0 || c.action != OutliersNone && (len(outliers) != 1 || outliers[0].Hash != "ffff" || outliers[0].Lines != 50000) {
+			t.Errorf("%v: unexpected outliers %v", c.action, outliers)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"io/ioutil"
 	"testing"
 	"time"
@@ -59,7 +60,7 @@ func TestGetScores(t *testing.T) {
 	c := NewContributor("", []PeriodTS{})
 
 	c.Contributions[0].SetScores(80.0, 10.0, 50.0)
-	expectedScore := c.Contributions[0].DifferenceScore*0.8 + c.Contributions[0].AdditionScore*0.1 + c.Contributions[0].CommitScore*0.1
+	expectedScore := c.Contributions[0].DifferenceScore*0.7 + c.Contributions[0].AdditionScore*0.15 + c.Contributions[0].CommitScore*0.15
 	if c.Contributions[0].GetScore() != expectedScore {
 		t.Errorf("The expected score was %v and we got %v", expectedScore, c.Contributions[0].GetScore())
 	}
@@ -190,7 +191,7 @@ func testParse(t *testing.T, file string) {
 		t.Errorf("Could not read the test file %v", err)
 	}
 
-	report, err := ParseStats(string(content), "/", *NewPeriodArray())
+	report, err := ParseStats(bytes.NewReader(content), nil, nil, "/", *NewPeriodArray(), *NewUserArray())
 	if err != nil {
 		t.Errorf("Reading a valid git log should not return an error")
 	}
@@ -250,7 +251,7 @@ func testParse(t *testing.T, file string) {
 		t.Errorf("Contributor2 should have 4 deletions")
 	}
 
-	report, err = ParseStats(string(content), "/test", *NewPeriodArray())
+	report, err = ParseStats(bytes.NewReader(content), nil, nil, "/test", *NewPeriodArray(), *NewUserArray())
 	contributors = []string{"Contributor1", "Contributor2"}
 	if !CheckContributors(report, contributors) {
 		t.Errorf("There's at least a missing contributor in the output")
@@ -296,7 +297,7 @@ func testParse(t *testing.T, file string) {
 		t.Errorf("Contributor2 should have 4 deletions")
 	}
 
-	report, err = ParseStats(string(content), "/tests", *NewPeriodArray())
+	report, err = ParseStats(bytes.NewReader(content), nil, nil, "/tests", *NewPeriodArray(), *NewUserArray())
 	if err != nil {
 		t.Errorf("%v", err)
 	}
@@ -316,3 +317,12 @@ func TestParseUnix(t *testing.T) {
 func TestParseWindows(t *testing.T) {
 	testParse(t, "test_assets/test_gitlogwin.txt")
 }
+
+func TestScoreShare(t *testing.T) {
+	if share := scoreShare(0, 0); share != 0 {
+		t.Errorf("A zero total should give a zero share, got %v", share)
+	}
+	if share := scoreShare(5, 20); share != 25 {
+		t.Errorf("The expected share was 25 and we got %v", share)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"path"
+	"strings"
+)
+
+// Path patterns, matched like in .gitignore: a pattern without a slash
+// matches the name of a file or of any of its directories, one with a slash
+// is matched from the root of the repository, ** standing for any number of
+// directories. A pattern matching a directory matches all its files.
+
+// defaultSelected are the files of the selected blame stage when the
+// configuration lists neither patterns nor languages
+var defaultSelected = []string{"*configure*", "*Makefile*", "*.h", "*.cpp", "*.c", "*.js"}
+
+// PathFilter selects the files the history and blame stages look at: those
+// matching one of the include patterns, or all of them when there are none,
+// and none of the exclude patterns. The selected blame stage is narrowed to
+// the files matching Selected or in one of SelectedLanguages.
+type PathFilter struct {
+	Include           []string `json:"include"`
+	Exclude           []string `json:"exclude"`
+	Selected          []string `json:"selected"`
+	SelectedLanguages []string `json:"selected_languages"`
+}
+
+func DecodePathFilter(jsonBlob []byte) (PathFilter, error) {
+	var filter PathFilter
+	err := json.Unmarshal(jsonBlob, &filter)
+	return filter, err
+}
+
+// matchSegments matches the parts of a path, the files inside a matched
+// directory matching too with directories
+func matchSegments(pattern, parts []string, directories bool) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:], directories) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], parts[0]); !matched {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0 || directories
+}
+
+func MatchPattern(pattern, name string) bool {
+	name = strings.TrimPrefix(name, "/")
+	if !strings.Contains(strings.TrimSuffix(pattern, "/"), "/") {
+		return matchSegments([]string{"**", strings.TrimSuffix(pattern, "/")}, strings.Split(name, "/"), true)
+	}
+	return matchSegments(strings.Split(strings.Trim(pattern, "/"), "/"), strings.Split(name, "/"), true)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if MatchPattern(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match tells whether a file, given by its path from the root of the
+// repository, is analysed
+func (f PathFilter) Match(name string) bool {
+	if len(f.Include) > 0 && !matchAny(f.Include, name) {
+		return false
+	}
+	return !matchAny(f.Exclude, name)
+}
+
+// MatchSelected tells whether a file is part of the selected blame stage
+func (f PathFilter) MatchSelected(name string) bool {
+	if !f.Match(name) {
+		return false
+	}
+	if len(f.Selected) == 0 && len(f.SelectedLanguages) == 0 {
+		return matchAny(defaultSelected, name)
+	}
+	language := Language(name)
+	for _, selected := range f.SelectedLanguages {
+		if strings.EqualFold(selected, language) {
+			return true
+		}
+	}
+	return matchAny(f.Selected, name)
+}
+
+// PatternList is a flag that can be given several times
+type PatternList []string
+
+func (p *PatternList) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *PatternList) Set(value string) error {
+	*p = append(*p, value)
+	return nil
+}
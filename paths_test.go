@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		name    string
+		matched bool
+	}{
+		{"*.c", "src/main.c", true},
+		{"*.c", "src/main.cpp", false},
+		{"extra_lib", "modules/extra_lib/zlib/inflate.c", true},
+		{"extra_lib", "modules/extra_library.c", false},
+		{"*Makefile*", "build/Makefile.am", true},
+		{"src/*.c", "src/main.c", true},
+		{"src/*.c", "lib/src/main.c", false},
+		{"/doc", "doc/index.md", true},
+		{"src/**/*.h", "src/a/b/c.h", true},
+		{"src/**/*.h", "src/c.h", true},
+		{"vendor/", "/vendor/lib.go", true},
+	}
+	for _, c := range cases {
+		if MatchPattern(c.pattern, c.name) != c.matched {
+			t.Errorf("Pattern %v on %v should give %v", c.pattern, c.name, c.matched)
+		}
+	}
+}
+
+func TestPathFilter(t *testing.T) {
+	filter, err := DecodePathFilter([]byte(`{"include": ["src", "Makefile"], "exclude": ["extra_lib"], "periods": []}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filter.Match("src/main.c") || filter.Match("src/extra_lib/zlib.c") || filter.Match("doc/index.md") {
+		t.Errorf("The files should be included then excluded")
+	}
+	if !filter.MatchSelected("src/main.c") || filter.MatchSelected("src/main.go") || filter.MatchSelected("src/extra_lib/zlib.c") {
+		t.Errorf("The selected files should default to the C sources and build files")
+	}
+	if !(PathFilter{}).Match("doc/index.md") {
+		t.Errorf("An empty filter should match everything")
+	}
+
+	parser := NewParser("/", *NewPeriodArray(), *NewUserArray())
+	parser.Paths = filter
+	parser.ParseHistory(strings.NewReader("Contributor1|Mon May 30 22:08:53 2016 +0200\n\n2\t0\tsrc/main.c\n3\t0\tsrc/extra_lib/zlib.c\n4\t0\tdoc/index.md\n"))
+	if parser.Report.TotalAdditions != 2 {
+		t.Errorf("The history should only count the filtered files, got %v additions", parser.Report.TotalAdditions)
+	}
+}
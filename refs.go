@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"github.com/RodolpheFouquet/termtables"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Refs walked besides the tip, so that the work of the branches not merged
+// yet is counted too. The commits shared by several refs count once.
+
+// allRefs are the patterns of -all-refs: the local and remote-tracking
+// branches
+var allRefs = []string{"refs/heads", "refs/remotes"}
+
+// MatchRef tells whether a ref matches a pattern, refs/ being prepended
+// like git --glob does: the pattern matches as a glob, or as a directory of
+// refs
+func MatchRef(pattern, ref string) bool {
+	if !strings.HasPrefix(pattern, "refs/") {
+		pattern = "refs/" + pattern
+	}
+	pattern = strings.TrimSuffix(pattern, "/")
+	if matched, _ := path.Match(pattern, ref); matched {
+		return true
+	}
+	return strings.HasPrefix(ref, pattern+"/")
+}
+
+// ListRefs returns the full names of the refs matching the patterns, the
+// symbolic HEAD of the remotes apart
+func ListRefs(repo string, patterns []string, useGit bool) ([]string, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	var names []string
+	if useGit {
+		out, err := runGit(repo, "for-each-ref", "--format=%(refname)")
+		if err != nil {
+			return nil, err
+		}
+		names = strings.Fields(string(out))
+	} else {
+		store, err := OpenObjectStore(repo)
+		if err != nil {
+			return nil, err
+		}
+		defer store.Close()
+		names = store.RefNames()
+	}
+	var refs []string
+	for _, name := range names {
+		if strings.HasSuffix(name, "/HEAD") {
+			continue
+		}
+		for _, pattern := range patterns {
+			if MatchRef(pattern, name) {
+				refs = append(refs, name)
+				break
+			}
+		}
+	}
+	return refs, nil
+}
+
+// RefNames lists the loose and packed refs
+func (s *ObjectStore) RefNames() []string {
+	seen := make(map[string]bool)
+	for name := range s.PackedRefs() {
+		seen[name] = true
+	}
+	refsDir := filepath.Join(s.commonDir(), "refs")
+	filepath.Walk(refsDir, func(file string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			if rel, err := filepath.Rel(s.commonDir(), file); err == nil {
+				seen[filepath.ToSlash(rel)] = true
+			}
+		}
+		return nil
+	})
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// shortRef names a branch like git branch -a does
+func shortRef(ref string) string {
+	for _, prefix := range []string{"refs/heads/", "refs/remotes/", "refs/"} {
+		if strings.HasPrefix(ref, prefix) {
+			return strings.TrimPrefix(ref, prefix)
+		}
+	}
+	return ref
+}
+
+// UnmergedCommits returns the branches holding each commit of the refs which
+// the tip doesn't have
+func UnmergedCommits(repo string, revs Revisions, useGit bool) (map[string][]string, error) {
+	unmerged := make(map[string][]string)
+	if len(revs.Refs) == 0 {
+		return unmerged, nil
+	}
+	excluded := []string{revs.Tip()}
+	if revs.Range != "" {
+		start, _ := revs.SplitRange()
+		excluded = append(excluded, start)
+	}
+	if useGit {
+		for _, ref := range revs.Refs {
+			args := []string{"rev-list", ref, "--not"}
+			out, err := runGit(repo, append(append(args, excluded...), "--")...)
+			if err != nil {
+				return nil, err
+			}
+			for _, hash := range strings.Fields(string(out)) {
+				unmerged[hash] = append(unmerged[hash], shortRef(ref))
+			}
+		}
+		return unmerged, nil
+	}
+	store, err := OpenObjectStore(repo)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	var excludedHashes []string
+	for _, rev := range excluded {
+		hash, err := store.ResolveRevision(rev)
+		if err != nil {
+			return nil, err
+		}
+		excludedHashes = append(excludedHashes, hash)
+	}
+	merged := make(map[string]bool)
+	err = store.WalkCommits(excludedHashes, nil, false, func(commit *Commit) error {
+		merged[commit.Hash] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range revs.Refs {
+		hash, err := store.ResolveRevision(ref)
+		if err != nil {
+			return nil, err
+		}
+		err = store.WalkCommits([]string{hash}, merged, false, func(commit *Commit) error {
+			unmerged[commit.Hash] = append(unmerged[commit.Hash], shortRef(ref))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return unmerged, nil
+}
+
+// SetUnmerged gives the branches of the commits of the repository being
+// parsed which its tip doesn't have
+func (p *Parser) SetUnmerged(unmerged map[string][]string) {
+	p.unmerged = unmerged
+}
+
+// IncrementUnmerged counts an unmerged commit on each of its branches
+func (r *Report) IncrementUnmerged(names []string, branches []string, date time.Time) {
+	for _, name := range names {
+		if !r.HasContributor(name) {
+			continue
+		}
+		contrib := GetContribution(r.Contributors[name].Contributions, date)
+		if contrib.Unmerged == nil {
+			contrib.Unmerged = make(map[string]int)
+		}
+		for _, branch := range branches {
+			contrib.Unmerged[branch]++
+		}
+	}
+}
+
+// PrintUnmerged lists the branches where the unmerged work of each
+// contributor lives, with its number of commits
+func PrintUnmerged(report *Report) {
+	var contributions []*Contribution
+	for _, contributor := range report.Contributors {
+		for _, contribution := range contributor.Contributions {
+			if len(contribution.Unmerged) > 0 {
+				contributions = append(contributions, contribution)
+			}
+		}
+	}
+	if len(contributions) == 0 {
+		return
+	}
+	sort.Slice(contributions, func(i, j int) bool { return contributions[i].Name < contributions[j].Name })
+	fmt.Println("Unmerged work")
+	table := termtables.CreateTable()
+	table.AddHeaders("Contributor", "Branches (commits)")
+	for _, contribution := range contributions {
+		var branches []string
+		for branch, commits := range contribution.Unmerged {
+			branches = append(branches, fmt.Sprintf("%v (%v)", branch, commits))
+		}
+		sort.Strings(branches)
+		table.AddRow(contribution.Name, strings.Join(branches, ", "))
+	}
+	fmt.Println(table.Render())
+}
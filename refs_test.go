@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatchRef(t *testing.T) {
+	cases := []struct {
+		pattern string
+		ref     string
+		matched bool
+	}{
+		{"refs/heads", "refs/heads/feature/login", true},
+		{"refs/remotes", "refs/remotes/origin/main", true},
+		{"heads/release/*", "refs/heads/release/1.2", true},
+		{"heads/release/*", "refs/heads/main", false},
+		{"remotes/origin/wip", "refs/remotes/origin/wip2", false},
+		{"tags", "refs/tags/v1.0", true},
+	}
+	for _, c := range cases {
+		if MatchRef(c.pattern, c.ref) != c.matched {
+			t.Errorf("%v should match %v: %v", c.pattern, c.ref, c.matched)
+		}
+	}
+	if shortRef("refs/remotes/origin/wip") != "origin/wip" || shortRef("refs/heads/main") != "main" {
+		t.Errorf("The branches should be named like git branch -a does")
+	}
+}
+
+func TestRefsArgs(t *testing.T) {
+	revs := Revisions{Range: "v1.0..", Refs: []string{"refs/heads/wip"}}
+	if args := revs.LogArgs(); !reflect.DeepEqual(args, []string{"v1.0..", "refs/heads/wip"}) {
+		t.Errorf("Unexpected log arguments %v", args)
+	}
+}
+
+func TestUnmergedWork(t *testing.T) {
+	log := "Bob|Tue May 31 22:08:53 2016 +0200||b@example.com|Bob|b@example.com|Tue May 31 22:08:53 2016 +0200|bbbb|aaaa\n\n3\t0\tsrc/wip.c\n" +
+		"Alice|Mon May 30 22:08:53 2016 +0200||a@example.com|Alice|a@example.com|Mon May 30 22:08:53 2016 +0200|aaaa|\n\n5\t0\tsrc/main.c\n"
+
+	parser := NewParser("/", *NewPeriodArray(), *NewUserArray())
+	parser.SetUnmerged(map[string][]string{"bbbb": {"wip", "origin/wip"}})
+	parser.ParseHistory(strings.NewReader(log))
+	bob := parser.Report.Contributors["Bob"].Contributions[0]
+	if !reflect.DeepEqual(bob.Unmerged, map[string]int{"wip": 1, "origin/wip": 1}) || bob.Commits != 1 || parser.Report.TotalCommits != 2 {
+		t.Errorf("The unmerged commit should be counted once and listed on its branches: %v", bob.Unmerged)
+	}
+	if len(parser.Report.Contributors["Alice"].Contributions[0].Unmerged) != 0 {
+		t.Errorf("The merged work should not be listed")
+	}
+}
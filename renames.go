@@ -0,0 +1,238 @@
+package main
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// Renames in the numstat output: "src/{old => new}/file.c" or "old => new"
+
+// parseRenamePath returns the source and the destination of a numstat path,
+// both being the same when the file was not renamed
+func parseRenamePath(numstatPath string) (string, string) {
+	arrow := strings.Index(numstatPath, " => ")
+	if arrow < 0 {
+		return numstatPath, numstatPath
+	}
+	open := strings.LastIndex(numstatPath[:arrow], "{")
+	closing := strings.Index(numstatPath[arrow:], "}")
+	if open < 0 || closing < 0 {
+		return numstatPath[:arrow], numstatPath[arrow+4:]
+	}
+	closing += arrow
+	prefix := numstatPath[:open]
+	suffix := numstatPath[closing+1:]
+	oldPath := prefix + numstatPath[open+1:arrow] + suffix
+	newPath := prefix + numstatPath[arrow+4:closing] + suffix
+	// an empty side leaves a double slash: "dir/{ => sub}/file"
+	return strings.Replace(oldPath, "//", "/", 1), strings.Replace(newPath, "//", "/", 1)
+}
+
+// formatRenamePath is the reverse of parseRenamePath, it factors the common
+// directories out of the braces the way git does
+func formatRenamePath(a, b string) string {
+	pfx := 0
+	for i := 0; i < len(a) && i < len(b) && a[i] == b[i]; i++ {
+		if a[i] == '/' {
+			pfx = i + 1
+		}
+	}
+	at := func(s string, i int) byte {
+		if i >= len(s) {
+			return 0
+		}
+		return s[i]
+	}
+	// with a common prefix, the suffix may start on its final slash
+	adjust := 0
+	if pfx > 0 {
+		adjust = 1
+	}
+	sfx := 0
+	for i, j := len(a), len(b); i >= 0 && j >= 0 && pfx-adjust <= i && pfx-adjust <= j && at(a, i) == at(b, j); i, j = i-1, j-1 {
+		if at(a, i) == '/' {
+			sfx = len(a) - i
+		}
+	}
+	aMid := len(a) - pfx - sfx
+	bMid := len(b) - pfx - sfx
+	if aMid < 0 {
+		aMid = 0
+	}
+	if bMid < 0 {
+		bMid = 0
+	}
+	if pfx+sfx == 0 {
+		return a + " => " + b
+	}
+	return a[:pfx] + "{" + a[pfx:pfx+aMid] + " => " + b[pfx:pfx+bMid] + "}" + a[len(a)-sfx:]
+}
+
+// RenameTracker follows the files across moves: the history being read from
+// the newest commit, the former paths of a file are mapped to its latest one
+type RenameTracker struct {
+	latest map[string]string
+}
+
+func NewRenameTracker() *RenameTracker {
+	return &RenameTracker{latest: make(map[string]string)}
+}
+
+func (t *RenameTracker) Resolve(path string) string {
+	if latest, exists := t.latest[path]; exists {
+		return latest
+	}
+	return path
+}
+
+func (t *RenameTracker) Rename(oldPath, newPath string) {
+	t.latest[oldPath] = t.Resolve(newPath)
+}
+
+// Rename detection for the built-in reader. Exact renames are found like git
+// does, inexact ones with an estimate of the similarity based on the shared
+// lines instead of git's hashed chunks.
+
+const (
+	minRenameScore = 0.5
+	renameLimit    = 400 // files on each side, as diff.renameLimit
+)
+
+func lineCounts(content []byte) map[string]int {
+	counts := make(map[string]int)
+	for _, line := range splitLines(content) {
+		counts[line]++
+	}
+	return counts
+}
+
+func similarity(src, dst []byte, srcLines, dstLines map[string]int) float64 {
+	maxSize := len(src)
+	if len(dst) > maxSize {
+		maxSize = len(dst)
+	}
+	if maxSize == 0 {
+		return 1
+	}
+	delta := len(src) - len(dst)
+	if delta < 0 {
+		delta = -delta
+	}
+	if float64(delta) > float64(maxSize)*(1-minRenameScore) {
+		return 0
+	}
+	copied := 0
+	for line, count := range srcLines {
+		if other := dstLines[line]; other < count {
+			copied += other * len(line)
+		} else {
+			copied += count * len(line)
+		}
+	}
+	return float64(copied) / float64(maxSize)
+}
+
+type renameCandidate struct {
+	deleted int
+	added   int
+	score   float64
+}
+
+// DetectRenames pairs the deleted and added files of a diff into renames
+func (s *ObjectStore) DetectRenames(changes []fileChange) ([]fileChange, error) {
+	var deleted, added []int
+	for i, change := range changes {
+		isFile := change.OldMode&0170000 != 0160000 && change.NewMode&0170000 != 0160000
+		if change.NewHash == "" && isFile {
+			deleted = append(deleted, i)
+		} else if change.OldHash == "" && isFile {
+			added = append(added, i)
+		}
+	}
+	if len(deleted) == 0 || len(added) == 0 {
+		return changes, nil
+	}
+
+	sourceOf := make(map[int]int) // added -> deleted
+	used := make(map[int]bool)
+	byHash := make(map[string][]int)
+	for _, d := range deleted {
+		byHash[changes[d].OldHash] = append(byHash[changes[d].OldHash], d)
+	}
+	for _, a := range added {
+		best := -1
+		for _, d := range byHash[changes[a].NewHash] {
+			if used[d] {
+				continue
+			}
+			if best < 0 || path.Base(changes[d].Path) == path.Base(changes[a].Path) {
+				best = d
+			}
+		}
+		if best >= 0 {
+			used[best] = true
+			sourceOf[a] = best
+		}
+	}
+
+	var remainingDeleted, remainingAdded []int
+	for _, d := range deleted {
+		if !used[d] {
+			remainingDeleted = append(remainingDeleted, d)
+		}
+	}
+	for _, a := range added {
+		if _, paired := sourceOf[a]; !paired {
+			remainingAdded = append(remainingAdded, a)
+		}
+	}
+	if len(remainingDeleted) > 0 && len(remainingAdded) > 0 && len(remainingDeleted) <= renameLimit && len(remainingAdded) <= renameLimit {
+		contents := make(map[int][]byte)
+		lines := make(map[int]map[string]int)
+		for _, i := range append(append([]int(nil), remainingDeleted...), remainingAdded...) {
+			hash := changes[i].OldHash
+			if hash == "" {
+				hash = changes[i].NewHash
+			}
+			content, err := s.ReadBlob(hash)
+			if err != nil {
+				return nil, err
+			}
+			contents[i] = content
+			lines[i] = lineCounts(content)
+		}
+		var candidates []renameCandidate
+		for _, d := range remainingDeleted {
+			for _, a := range remainingAdded {
+				score := similarity(contents[d], contents[a], lines[d], lines[a])
+				if score >= minRenameScore {
+					candidates = append(candidates, renameCandidate{deleted: d, added: a, score: score})
+				}
+			}
+		}
+		sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+		for _, candidate := range candidates {
+			if _, paired := sourceOf[candidate.added]; paired || used[candidate.deleted] {
+				continue
+			}
+			used[candidate.deleted] = true
+			sourceOf[candidate.added] = candidate.deleted
+		}
+	}
+
+	var result []fileChange
+	for i, change := range changes {
+		if used[i] {
+			continue
+		}
+		if d, paired := sourceOf[i]; paired {
+			source := changes[d]
+			change.OldPath = source.Path
+			change.OldHash = source.OldHash
+			change.OldMode = source.OldMode
+		}
+		result = append(result, change)
+	}
+	return result, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+var renamePaths = []struct {
+	numstat string
+	oldPath string
+	newPath string
+}{
+	{"src/file.c", "src/file.c", "src/file.c"},
+	{"src/{old => new}/file.c", "src/old/file.c", "src/new/file.c"},
+	{"{lib => deep/er}/x.h", "lib/x.h", "deep/er/x.h"},
+	{"deep/{er => }/x.h", "deep/er/x.h", "deep/x.h"},
+	{"dir/{ => sub}/f", "dir/f", "dir/sub/f"},
+	{"src/new/a.c => a.c", "src/new/a.c", "a.c"},
+	{"src/{a.c => b.c}", "src/a.c", "src/b.c"},
+}
+
+func TestParseRenamePath(t *testing.T) {
+	for _, test := range renamePaths {
+		oldPath, newPath := parseRenamePath(test.numstat)
+		if oldPath != test.oldPath || newPath != test.newPath {
+			t.Errorf("%v should be parsed as %v => %v and we got %v => %v", test.numstat, test.oldPath, test.newPath, oldPath, newPath)
+		}
+		if test.oldPath != test.newPath {
+			if formatted := formatRenamePath(test.oldPath, test.newPath); formatted != test.numstat {
+				t.Errorf("%v => %v should be formatted as %v and we got %v", test.oldPath, test.newPath, test.numstat, formatted)
+			}
+		}
+	}
+}
+
+func TestRenameTracker(t *testing.T) {
+	tracker := NewRenameTracker()
+	// read from the newest commit: b.c became c.c, which a.c had become before
+	tracker.Rename("src/b.c", "src/c.c")
+	tracker.Rename("src/a.c", "src/b.c")
+	if tracker.Resolve("src/a.c") != "src/c.c" {
+		t.Errorf("src/a.c should resolve to src/c.c and resolved to %v", tracker.Resolve("src/a.c"))
+	}
+	if tracker.Resolve("src/d.c") != "src/d.c" {
+		t.Errorf("A file that never moved should keep its path")
+	}
+}
+
+func TestParseRenamedHistory(t *testing.T) {
+	log := "Contributor1|Mon May 30 22:08:53 2016 +0200\n\n1\t1\t{lib => test}/x.h\nContributor2|Mon May 30 22:08:53 2016 +0200\n\n10\t0\tlib/x.h\n"
+	report, _ := ParseStats(strings.NewReader(log), nil, nil, "/test", *NewPeriodArray(), *NewUserArray())
+	if !report.HasContributor("Contributor1") || report.HasContributor("Contributor2") {
+		t.Errorf("Only the rename into the subtree should be counted")
+	}
+
+	parser := NewParser("/test", *NewPeriodArray(), *NewUserArray())
+	parser.FollowRenames()
+	parser.ParseHistory(strings.NewReader(log))
+	if !parser.Report.HasContributor("Contributor2") || parser.Report.TotalAdditions != 11 {
+		t.Errorf("The changes made before the file was moved should be followed")
+	}
+}
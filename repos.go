@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RepoList is a flag that can be given several times
+type RepoList []string
+
+func (r *RepoList) String() string {
+	return strings.Join(*r, ",")
+}
+
+func (r *RepoList) Set(value string) error {
+	*r = append(*r, value)
+	return nil
+}
+
+// ReadManifest reads the repositories listed in a file, one per line, the
+// relative paths being relative to the manifest. Empty lines and lines
+// starting with # are ignored.
+func ReadManifest(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var repos []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(filepath.Dir(path), line)
+		}
+		repos = append(repos, line)
+	}
+	return repos, scanner.Err()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "git-stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	manifest := filepath.Join(dir, "repos.txt")
+	ioutil.WriteFile(manifest, []byte("# product\ncore\n\n  /abs/ui  \n"), 0644)
+
+	repos, err := ReadManifest(manifest)
+	if err != nil {
+		t.Errorf("Reading a valid manifest should not fail: %v", err)
+	}
+	if len(repos) != 2 || repos[0] != filepath.Join(dir, "core") || repos[1] != "/abs/ui" {
+		t.Errorf("Unexpected repositories %v", repos)
+	}
+}
+
+func TestSubtotals(t *testing.T) {
+	r := NewReport()
+	r.AddContributor("Pouet", make(map[string][]PeriodTS))
+	r.StartRepository("first")
+	r.IncrementCommits("Pouet", time.Now())
+	r.IncrementCounters("Pouet", 10, 2, time.Now())
+	r.StartRepository("second")
+	r.IncrementCounters("Pouet", 5, 1, time.Now())
+
+	if len(r.Subtotals) != 2 {
+		t.Errorf("There should be a subtotal per repository")
+	}
+	if r.Subtotals[0].Additions != 10 || r.Subtotals[0].Deletions != 2 || r.Subtotals[0].Commits != 1 {
+		t.Errorf("Unexpected subtotal %v", r.Subtotals[0])
+	}
+	if r.Subtotals[1].Additions != 5 || r.Subtotals[1].Commits != 0 {
+		t.Errorf("Unexpected subtotal %v", r.Subtotals[1])
+	}
+	if r.TotalAdditions != 15 || r.Contributors["Pouet"].Contributions[0].Additions != 15 {
+		t.Errorf("The repositories should be merged in the totals")
+	}
+}
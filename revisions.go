@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Revisions selects the part of the history looked at by both the log and
+// the blame stages
+type Revisions struct {
+	Since       string   // date
+	Until       string   // date
+	Range       string   // A..B
+	At          string   // ref
+	Ignored     []string // full hashes of the commits the blame passes through
+	FirstParent bool     // the mainline alone
+	Refs        []string // full names of the refs walked besides the tip
+}
+
+func (r Revisions) Validate() error {
+	if r.Range != "" && r.At != "" {
+		return errors.New("-rev-range and -at can't be used together")
+	}
+	if r.Range != "" {
+		if strings.Contains(r.Range, "...") || !strings.Contains(r.Range, "..") {
+			return fmt.Errorf("invalid revision range %v, expected A..B", r.Range)
+		}
+	}
+	return nil
+}
+
+// Limited is true when lines older than the selection have to be left out
+func (r Revisions) Limited() bool {
+	return r.Range != "" || r.Since != ""
+}
+
+// SplitRange returns both ends of the range, B defaulting to HEAD
+func (r Revisions) SplitRange() (string, string) {
+	ends := strings.SplitN(r.Range, "..", 2)
+	if ends[1] == "" {
+		ends[1] = "HEAD"
+	}
+	return ends[0], ends[1]
+}
+
+// Tip is the revision the history starts from and the blame looks at
+func (r Revisions) Tip() string {
+	if r.Range != "" {
+		_, tip := r.SplitRange()
+		return tip
+	}
+	if r.At != "" {
+		return r.At
+	}
+	return "HEAD"
+}
+
+func (r Revisions) LogArgs() []string {
+	var args []string
+	if r.Since != "" {
+		args = append(args, "--since="+r.Since)
+	}
+	if r.Until != "" {
+		args = append(args, "--until="+r.Until)
+	}
+	if r.FirstParent {
+		args = append(args, "--first-parent")
+	}
+	if r.Range != "" {
+		args = append(args, r.Range)
+	} else {
+		args = append(args, r.Tip())
+	}
+	return append(args, r.Refs...)
+}
+
+// BlameRevision resolves the commit the files are blamed at: the tip of the
+// selection, or the last commit before -until, empty when -until is before the
+// first commit
+func (r Revisions) BlameRevision(repo string) (string, error) {
+	args := []string{"rev-list", "-1"}
+	if r.Until != "" {
+		args = append(args, "--until="+r.Until)
+	}
+	out, err := runGit(repo, append(args, r.Tip(), "--")...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// ResolveSince turns -since into the timestamp git reads it as, so that the
+// blame cache tells apart the runs where a date like "2 weeks ago", or a
+// day taken at the current time, stands for another time
+func (r Revisions) ResolveSince(repo string) (Revisions, error) {
+	if r.Since == "" {
+		return r, nil
+	}
+	out, err := runGit(repo, "rev-parse", "--since="+r.Since)
+	if err != nil {
+		return r, err
+	}
+	// --max-age=<timestamp>
+	r.Since = "@" + strings.TrimPrefix(strings.TrimSpace(string(out)), "--max-age=")
+	return r, nil
+}
+
+// BlameArgs are the options of git blame for the revision resolved by
+// BlameRevision, the lines from outside of the selection are then marked as
+// boundaries
+func (r Revisions) BlameArgs(rev string) []string {
+	args := []string{"--root"}
+	if r.Since != "" {
+		args = append(args, "--since="+r.Since)
+	}
+	if r.FirstParent {
+		args = append(args, "--first-parent")
+	}
+	for _, hash := range r.Ignored {
+		args = append(args, "--ignore-rev", hash)
+	}
+	if r.Range != "" {
+		start, _ := r.SplitRange()
+		return append(args, start+".."+rev)
+	}
+	return append(args, rev)
+}
+
+// parseDate reads the dates the built-in reader understands, git itself
+// accepts many more
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05"} {
+		if date, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return date, nil
+		}
+	}
+	// like git, a day without a time stands for that day at the current time
+	if day, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		now := time.Now()
+		return time.Date(day.Year(), day.Month(), day.Day(), now.Hour(), now.Minute(), now.Second(), 0, time.Local), nil
+	}
+	return time.Time{}, fmt.Errorf("unsupported date %v, use YYYY-MM-DD", value)
+}
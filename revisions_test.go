@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testCommit is a commit of newTestRepo, an empty content deleting a file
+type testCommit struct {
+	Author    string
+	Committer string // the author when empty
+	Date      string
+	Files     map[string]string
+}
+
+// newTestRepo creates a repository with the commits, skipping the test
+// without git
+func newTestRepo(t *testing.T, commits []testCommit) string {
+	if !HasGit() {
+		t.Skip("git was not found")
+	}
+	repo := t.TempDir()
+	git := func(env []string, args ...string) {
+		command := exec.Command("git", append([]string{"-C", repo}, args...)...)
+		command.Env = append(os.Environ(), env...)
+		if out, err := command.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+	}
+	git(nil, "init", "-q")
+	for _, commit := range commits {
+		for name, content := range commit.Files {
+			file := filepath.Join(repo, name)
+			if content == "" {
+				os.Remove(file)
+				continue
+			}
+			os.MkdirAll(filepath.Dir(file), 0755)
+			if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		committer := commit.Committer
+		if committer == "" {
+			committer = commit.Author
+		}
+		git(nil, "add", "-A")
+		git([]string{"GIT_AUTHOR_NAME=" + commit.Author, "GIT_AUTHOR_EMAIL=" + commit.Author + "@example.com", "GIT_AUTHOR_DATE=" + commit.Date,
+			"GIT_COMMITTER_NAME=" + committer, "GIT_COMMITTER_EMAIL=" + committer + "@example.com", "GIT_COMMITTER_DATE=" + commit.Date},
+			"commit", "-q", "--allow-empty", "-m", "commit by "+commit.Author)
+	}
+	return repo
+}
+
+func TestRevisionsValidate(t *testing.T) {
+	if err := (Revisions{Range: "v1.0..v2.0", At: "v2.0"}).Validate(); err == nil {
+		t.Errorf("A range and a ref can't be given together")
+	}
+	if err := (Revisions{Range: "v1.0...v2.0"}).Validate(); err == nil {
+		t.Errorf("Symmetric differences are not supported")
+	}
+	if err := (Revisions{Range: "v1.0.."}).Validate(); err != nil {
+		t.Errorf("An open range is valid: %v", err)
+	}
+}
+
+func TestRevisionsArgs(t *testing.T) {
+	revs := Revisions{Since: "2016-01-01", Range: "v1.0.."}
+	if revs.Tip() != "HEAD" {
+		t.Errorf("An open range ends at HEAD, got %v", revs.Tip())
+	}
+	if args := revs.LogArgs(); !reflect.DeepEqual(args, []string{"--since=2016-01-01", "v1.0.."}) {
+		t.Errorf("Unexpected log arguments %v", args)
+	}
+	if args := revs.BlameArgs("abcdef"); !reflect.DeepEqual(args, []string{"--root", "--since=2016-01-01", "v1.0..abcdef"}) {
+		t.Errorf("Unexpected blame arguments %v", args)
+	}
+	if !revs.Limited() {
+		t.Errorf("A range limits the blamed lines")
+	}
+
+	revs = Revisions{At: "v1.0"}
+	if args := revs.LogArgs(); !reflect.DeepEqual(args, []string{"v1.0"}) {
+		t.Errorf("Unexpected log arguments %v", args)
+	}
+	if revs.Limited() {
+		t.Errorf("A ref alone doesn't limit the blamed lines")
+	}
+}
+
+func TestResolveSince(t *testing.T) {
+	repo := newTestRepo(t, []testCommit{{Author: "Alice", Date: "2016-05-30T12:00:00", Files: map[string]string{"f.c": "a\n"}}})
+	revs, err := (Revisions{Since: "2 weeks ago"}).ResolveSince(repo)
+	if _, parseErr := strconv.ParseInt(strings.TrimPrefix(revs.Since, "@"), 10, 64); err != nil || !strings.HasPrefix(revs.Since, "@") || parseErr != nil {
+		t.Errorf("A relative date should become a timestamp, got %v %v", revs.Since, err)
+	}
+}
+
+func TestBlameRevisionEmptyWindow(t *testing.T) {
+	repo := newTestRepo(t, []testCommit{{Author: "Alice", Date: "2016-05-30T12:00:00", Files: map[string]string{"f.c": "a\n"}}})
+	if rev, err := (Revisions{Until: "2015-01-01"}).BlameRevision(repo); err != nil || rev != "" {
+		t.Errorf("Without any commit before -until, there should be nothing to blame, got %v %v", rev, err)
+	}
+	if rev, err := (Revisions{Until: "2017-01-01"}).BlameRevision(repo); err != nil || len(rev) != 40 {
+		t.Errorf("The last commit before -until should be blamed, got %v %v", rev, err)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ttacon/chalk"
+	"path/filepath"
+	"strings"
+)
+
+// Module is a subtree of the repositories reported on its own
+type Module struct {
+	Name    string `json:"name"`
+	Subtree string `json:"subtree"`
+}
+
+type ModuleArray struct {
+	Modules []Module `json:"modules"`
+}
+
+func DecodeModules(jsonBlob []byte) ([]Module, error) {
+	var modules ModuleArray
+	err := json.Unmarshal(jsonBlob, &modules)
+	return modules.Modules, err
+}
+
+// SubtreeModules reads the comma separated subtrees of -subtree, each one
+// named after its path
+func SubtreeModules(subtrees string) []Module {
+	var modules []Module
+	for _, subtree := range strings.Split(subtrees, ",") {
+		if subtree = strings.TrimSpace(subtree); subtree != "" {
+			modules = append(modules, Module{Name: subtree, Subtree: subtree})
+		}
+	}
+	return modules
+}
+
+// Section is the report of a subtree
+type Section struct {
+	Name    string
+	Subtree string
+	Report  *Report
+}
+
+func (s *Section) String() string {
+	if s.Name == s.Subtree {
+		return s.Subtree
+	}
+	return fmt.Sprintf("%v (%v)", s.Name, s.Subtree)
+}
+
+func inSubtree(subtree, name string) bool {
+	rel, err := filepath.Rel(subtree, name)
+	if err != nil {
+		fmt.Println(chalk.Yellow, "Relative Warning: ", err)
+	}
+	return !strings.Contains(rel, "..")
+}
+
+// SetModules gives each module a section. A single module is the overall
+// report itself.
+func (p *Parser) SetModules(modules []Module) {
+	p.Sections = nil
+	for _, module := range modules {
+		report := p.Report
+		if len(modules) > 1 {
+			report = NewReport()
+		}
+		p.Sections = append(p.Sections, &Section{Name: module.Name, Subtree: module.Subtree, Report: report})
+	}
+}
+
+// reports returns the overall report then those of the sections
+func (p *Parser) reports() []*Report {
+	reports := []*Report{p.Report}
+	for _, section := range p.Sections {
+		if section.Report != p.Report {
+			reports = append(reports, section.Report)
+		}
+	}
+	return reports
+}
+
+// reportsFor returns the reports crediting a file: the overall one and
+// those of the subtrees containing it, none when it is outside of them all
+func (p *Parser) reportsFor(name string) []*Report {
+	var reports []*Report
+	for _, section := range p.Sections {
+		if !inSubtree(section.Subtree, name) {
+			continue
+		}
+		if reports == nil {
+			reports = []*Report{p.Report}
+		}
+		if section.Report != p.Report {
+			reports = append(reports, section.Report)
+		}
+	}
+	return reports
+}
+
+// subtrees lists the subtrees of the sections
+func (p *Parser) subtrees() string {
+	var subtrees []string
+	for _, section := range p.Sections {
+		subtrees = append(subtrees, section.Subtree)
+	}
+	return strings.Join(subtrees, ", ")
+}
+
+func (p *Parser) StartRepository(repo string) {
+	for _, report := range p.reports() {
+		report.StartRepository(repo)
+	}
+}
+
+// mergeBlameFiles sums the blame of the files by report
+func (p *Parser) mergeBlameFiles(files []FileBlame) map[*Report]BlameCounts {
+	merged := make(map[*Report]BlameCounts)
+	for _, file := range files {
+		for _, report := range p.reportsFor("/" + file.Path) {
+			if merged[report] == nil {
+				merged[report] = make(BlameCounts)
+			}
+			merged[report].Merge(file.Counts)
+		}
+	}
+	return merged
+}
+
+// AddBlameFiles credits the lines of the blamed files, all of them or the
+// selected ones, to the reports of their subtrees
+func (p *Parser) AddBlameFiles(files []FileBlame, selected bool) {
+	merged := p.mergeBlameFiles(files)
+	for _, report := range p.reports() {
+		if counts, exists := merged[report]; exists {
+			addBlameCounts(counts, selected, report, p.identities, p.periodMap)
+		}
+	}
+}
+
+func (p *Parser) AddGeneratedBlameFiles(files []FileBlame) {
+	merged := p.mergeBlameFiles(files)
+	for _, report := range p.reports() {
+		if counts, exists := merged[report]; exists {
+			addGeneratedBlameCounts(counts, report, p.identities, p.periodMap)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSubtreeModules(t *testing.T) {
+	modules := SubtreeModules("/src, /doc,")
+	if len(modules) != 2 || modules[1].Name != "/doc" || modules[1].Subtree != "/doc" {
+		t.Errorf("Unexpected modules %v", modules)
+	}
+	modules, err := DecodeModules([]byte(`{"modules": [{"name": "Core", "subtree": "/src/core"}], "users": []}`))
+	if err != nil || len(modules) != 1 || modules[0].Name != "Core" || modules[0].Subtree != "/src/core" {
+		t.Errorf("Unexpected modules %v %v", modules, err)
+	}
+}
+
+func TestSections(t *testing.T) {
+	parser := NewParser("/src,/doc", *NewPeriodArray(), *NewUserArray())
+	parser.ParseHistory(strings.NewReader("Contributor1|Mon May 30 22:08:53 2016 +0200\n\n2\t1\tsrc/main.c\n3\t0\tdoc/index.md\n5\t0\tMakefile\n"))
+	parser.AddBlameFiles([]FileBlame{
+		{Path: "src/main.c", Counts: BlameCounts{{Identity: Identity{Name: "Contributor1"}}: 4}},
+		{Path: "Makefile", Counts: BlameCounts{{Identity: Identity{Name: "Contributor1"}}: 7}},
+	}, false)
+
+	src, doc := parser.Sections[0].Report, parser.Sections[1].Report
+	if src.TotalAdditions != 2 || src.TotalOwnedLines != 4 || src.TotalCommits != 1 {
+		t.Errorf("The src section should have its history and blame, got %v additions", src.TotalAdditions)
+	}
+	if doc.TotalAdditions != 3 || doc.TotalOwnedLines != 0 || doc.TotalCommits != 1 {
+		t.Errorf("The doc section should have its history only, got %v additions", doc.TotalAdditions)
+	}
+	if parser.Report.TotalAdditions != 5 || parser.Report.TotalOwnedLines != 4 || parser.Report.TotalCommits != 1 {
+		t.Errorf("The overall report should count the subtrees once, got %v additions", parser.Report.TotalAdditions)
+	}
+
+	single := NewParser("/src", *NewPeriodArray(), *NewUserArray())
+	if len(single.Sections) != 1 || single.Sections[0].Report != single.Report {
+		t.Errorf("A single subtree should be the overall report")
+	}
+}
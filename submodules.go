@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/ttacon/chalk"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Submodule is a repository recorded in the tree of another one, analysed
+// at the commit its parent points to
+type Submodule struct {
+	Name   string // from .gitmodules, the path when it isn't listed there
+	Path   string
+	Commit string
+	Start  string // commit recorded at the start of the range, if any
+}
+
+// Revisions selects the history of the submodule matching the one of its
+// parent
+func (s Submodule) Revisions(parent Revisions) Revisions {
+	revs := Revisions{Since: parent.Since, Until: parent.Until, FirstParent: parent.FirstParent}
+	if s.Start != "" {
+		revs.Range = s.Start + ".." + s.Commit
+	} else {
+		// the whole history when the submodule was added within the range
+		revs.At = s.Commit
+	}
+	return revs
+}
+
+// Options are those of the parent with the revisions of the submodule and
+// its path as prefix. Only the recorded commits are walked, the refs of the
+// submodule and their unmerged work being left out.
+func (s Submodule) Options(parent Options) Options {
+	options := parent
+	options.Revisions = s.Revisions(parent.Revisions)
+	options.Prefix = path.Join(parent.Prefix, s.Path)
+	options.RefPatterns = nil
+	return options
+}
+
+// Locate returns where the submodule is checked out: its work tree in the
+// one of its parent, or the repository kept in .git/modules
+func (s Submodule) Locate(repo string) (string, error) {
+	candidates := []string{filepath.Join(repo, filepath.FromSlash(s.Path))}
+	if gitDir, err := FindGitDir(repo); err == nil {
+		candidates = append(candidates, filepath.Join(gitDir, "modules", filepath.FromSlash(s.Name)))
+	}
+	for _, candidate := range candidates {
+		store, err := OpenObjectStore(candidate)
+		if err != nil {
+			continue
+		}
+		found := store.HasObject(s.Commit)
+		store.Close()
+		if found {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("commit %v is not checked out", s.Commit)
+}
+
+// parseGitmodules maps the paths of the submodules to their names
+func parseGitmodules(reader io.Reader) (map[string]string, error) {
+	names := make(map[string]string)
+	scanner := bufio.NewScanner(reader)
+	name := ""
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			name = ""
+			// [submodule "name"]
+			if section := strings.TrimSuffix(strings.TrimPrefix(line, "["), "]"); strings.HasPrefix(section, "submodule ") {
+				name = strings.Trim(strings.TrimSpace(strings.TrimPrefix(section, "submodule ")), `"`)
+			}
+			continue
+		}
+		key, value := line, ""
+		if equal := strings.IndexByte(line, '='); equal >= 0 {
+			key, value = strings.TrimSpace(line[:equal]), strings.TrimSpace(line[equal+1:])
+		}
+		if name != "" && key == "path" {
+			names[strings.Trim(value, `"`)] = name
+		}
+	}
+	return names, scanner.Err()
+}
+
+// gitlinks returns the commit of each submodule of a tree, by path
+func (s *ObjectStore) gitlinks(tree, prefix string, links map[string]string) error {
+	entries, err := s.ReadTree(tree)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsSubmodule() {
+			links[path.Join(prefix, entry.Name)] = entry.Hash
+		} else if entry.IsTree() {
+			if err := s.gitlinks(entry.Hash, path.Join(prefix, entry.Name), links); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *ObjectStore) gitlinksAt(rev string) (map[string]string, error) {
+	hash, err := s.ResolveRevision(rev)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := s.ReadCommit(hash)
+	if err != nil {
+		return nil, err
+	}
+	links := make(map[string]string)
+	return links, s.gitlinks(commit.Tree, "", links)
+}
+
+// ListSubmodules returns the submodules recorded at the tip of the selected
+// revisions, with the commits they pointed to at the start of a range
+func ListSubmodules(repo string, revs Revisions) ([]Submodule, error) {
+	store, err := OpenObjectStore(repo)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	links, err := store.gitlinksAt(revs.Tip())
+	if err != nil {
+		return nil, err
+	}
+	if len(links) == 0 {
+		return nil, nil
+	}
+	startLinks := make(map[string]string)
+	if revs.Range != "" {
+		start, _ := revs.SplitRange()
+		if startLinks, err = store.gitlinksAt(start); err != nil {
+			return nil, err
+		}
+	}
+	names := make(map[string]string)
+	if content, err := ReadFileAt(repo, revs.Tip(), ".gitmodules"); err == nil && content != nil {
+		if names, err = parseGitmodules(strings.NewReader(string(content))); err != nil {
+			fmt.Println(chalk.Yellow, "Invalid .gitmodules: ", err)
+		}
+	}
+
+	var submodules []Submodule
+	for linkPath, commit := range links {
+		name, exists := names[linkPath]
+		if !exists {
+			name = linkPath
+		}
+		submodules = append(submodules, Submodule{Name: name, Path: linkPath, Commit: commit, Start: startLinks[linkPath]})
+	}
+	sort.Slice(submodules, func(i, j int) bool { return submodules[i].Path < submodules[j].Path })
+	return submodules, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseGitmodules(t *testing.T) {
+	gitmodules := "[submodule \"core\"]\n\tpath = libs/core\n\turl = ../core.git\n[core]\n\tpath = ignored\n[submodule \"ui\"]\n\tpath = \"ui\"\n"
+	names, err := parseGitmodules(strings.NewReader(gitmodules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names["libs/core"] != "core" || names["ui"] != "ui" {
+		t.Errorf("Unexpected submodule names %v", names)
+	}
+}
+
+func TestSubmoduleRevisions(t *testing.T) {
+	parent := Revisions{Since: "2016-01-01", Range: "v1.0..v2.0"}
+	revs := Submodule{Path: "libs/core", Commit: "bbbb", Start: "aaaa"}.Revisions(parent)
+	if revs.Range != "aaaa..bbbb" || revs.Since != "2016-01-01" || revs.At != "" {
+		t.Errorf("The range should go from the commit recorded at the start to the one at the tip: %v", revs)
+	}
+	revs = Submodule{Path: "libs/core", Commit: "bbbb"}.Revisions(parent)
+	if revs.Range != "" || revs.At != "bbbb" {
+		t.Errorf("A submodule added in the range should be analysed up to its commit: %v", revs)
+	}
+}
+
+func TestSubmoduleOptions(t *testing.T) {
+	parent := Options{Revisions: Revisions{Refs: []string{"refs/heads/topic"}}, Prefix: "vendor", RefPatterns: []string{"refs/heads"}}
+	options := Submodule{Path: "libs/core", Commit: "bbbb"}.Options(parent)
+	if options.Prefix != "vendor/libs/core" || options.Revisions.At != "bbbb" || len(options.RefPatterns) != 0 || len(options.Revisions.Refs) != 0 {
+		t.Errorf("The submodule should only be walked from its recorded commit: %+v", options)
+	}
+}
+
+func TestSubmodulePrefix(t *testing.T) {
+	parser := NewParser("/libs", *NewPeriodArray(), *NewUserArray())
+	parser.gitlinks = map[string]bool{"/libs/core": true}
+	parser.ParseHistory(strings.NewReader("Contributor1|Mon May 30 22:08:53 2016 +0200\n\n1\t1\tlibs/core\n2\t0\tmain.c\n"))
+	if len(parser.Report.Contributors) != 0 {
+		t.Errorf("The updates of a submodule commit should not be credited")
+	}
+
+	parser.Prefix = "libs/core"
+	parser.ParseHistory(strings.NewReader("Contributor2|Mon May 30 22:08:53 2016 +0200\n\n3\t0\tsrc/core.c\n"))
+	if !CheckContributors(parser.Report, []string{"Contributor2"}) || parser.Report.TotalAdditions != 3 {
+		t.Errorf("The paths of the submodule should be prefixed to match the subtree")
+	}
+}
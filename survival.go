@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"github.com/RodolpheFouquet/termtables"
+	"math"
+	"sort"
+	"time"
+)
+
+// Code survival: how many of the lines a contributor wrote are still in the
+// files at the tip, how old they are, and how long their lines last. The
+// lines written come from the history and the surviving ones from the blame
+// of all the files, both kept by day so that their ages can be told.
+
+const secondsPerDay = 24 * 60 * 60
+
+// survivalAges are the buckets of the histogram of the surviving lines, by
+// their age in days
+var survivalAges = []struct {
+	Name string
+	Days int64
+}{
+	{"< 1 month", 30},
+	{"1-6 months", 182},
+	{"6-12 months", 365},
+	{"1-2 years", 730},
+	{"2-5 years", 1826},
+	{"> 5 years", math.MaxInt64},
+}
+
+// Survival holds the lines written and the ones still alive, by day since
+// the epoch
+type Survival struct {
+	Written map[int64]int
+	Alive   map[int64]int
+}
+
+func NewSurvival() *Survival {
+	return &Survival{Written: make(map[int64]int), Alive: make(map[int64]int)}
+}
+
+func dayOf(date time.Time) int64 {
+	return date.Unix() / secondsPerDay
+}
+
+func sumDays(days map[int64]int) int {
+	total := 0
+	for _, lines := range days {
+		total += lines
+	}
+	return total
+}
+
+func (s *Survival) TotalWritten() int {
+	return sumDays(s.Written)
+}
+
+func (s *Survival) TotalAlive() int {
+	return sumDays(s.Alive)
+}
+
+func (s *Survival) Merge(other *Survival) {
+	for day, lines := range other.Written {
+		s.Written[day] += lines
+	}
+	for day, lines := range other.Alive {
+		s.Alive[day] += lines
+	}
+}
+
+// latestDay is the last day lines were written or blamed, which the ages
+// are counted from
+func (s *Survival) latestDay() int64 {
+	latest := int64(math.MinInt64)
+	for _, days := range []map[int64]int{s.Written, s.Alive} {
+		for day := range days {
+			if day > latest {
+				latest = day
+			}
+		}
+	}
+	return latest
+}
+
+// Histogram returns the surviving lines by bucket of survivalAges, as of
+// the reference day
+func (s *Survival) Histogram(reference int64) []int {
+	histogram := make([]int, len(survivalAges))
+	for day, lines := range s.Alive {
+		age := reference - day
+		for bucket, ages := range survivalAges {
+			if age < ages.Days {
+				histogram[bucket] += lines
+				break
+			}
+		}
+	}
+	return histogram
+}
+
+// HalfLife estimates in days how long half of the lines last, fitting an
+// exponential decay to the lines written and alive as of the reference day:
+// the decay rate is the one that makes the lines written then survive in
+// the number found. It is unknown when no line died or none survived.
+func (s *Survival) HalfLife(reference int64) (float64, bool) {
+	written, alive := s.TotalWritten(), s.TotalAlive()
+	if alive == 0 || alive >= written {
+		return 0, false
+	}
+	expected := func(rate float64) float64 {
+		sum := 0.0
+		for day, lines := range s.Written {
+			sum += float64(lines) * math.Exp(-rate*float64(reference-day))
+		}
+		return sum
+	}
+	low, high := 0.0, 1.0
+	for expected(high) > float64(alive) {
+		low, high = high, high*2
+		if high > 1e6 {
+			// the lines died faster than a day can tell
+			return 0, false
+		}
+	}
+	for i := 0; i < 100; i++ {
+		middle := (low + high) / 2
+		if expected(middle) > float64(alive) {
+			low = middle
+		} else {
+			high = middle
+		}
+	}
+	return math.Ln2 / ((low + high) / 2), true
+}
+
+func (r *Report) survival(name string, date time.Time) *Survival {
+	contrib := GetContribution(r.Contributors[name].Contributions, date)
+	if contrib.Survival == nil {
+		contrib.Survival = NewSurvival()
+	}
+	return contrib.Survival
+}
+
+// IncrementWritten records the lines added by a commit, divided like
+// IncrementSharedCounters
+func (r *Report) IncrementWritten(names []string, additions int, split bool, date time.Time) {
+	for index, name := range names {
+		if !r.HasContributor(name) {
+			continue
+		}
+		contribAdditions := additions
+		if split {
+			contribAdditions = splitShare(additions, len(names), index)
+		}
+		if contribAdditions > 0 {
+			r.survival(name, date).Written[dayOf(date)] += contribAdditions
+		}
+	}
+}
+
+// IncrementAlive records blamed lines, the ones of an unknown time apart
+func (r *Report) IncrementAlive(name string, lines int, date time.Time) {
+	if !r.HasContributor(name) || date.IsZero() {
+		return
+	}
+	r.survival(name, date).Alive[dayOf(date)] += lines
+}
+
+// AddSurvivingFiles records the lines of the blamed files still alive, in
+// the reports of their subtrees
+func (p *Parser) AddSurvivingFiles(files []FileBlame) {
+	merged := p.mergeBlameFiles(files)
+	for _, report := range p.reports() {
+		if counts, exists := merged[report]; exists {
+			creditBlameCounts(counts, p.identities, func(contributor string, lines int, date time.Time) {
+				report.IncrementAlive(contributor, lines, date)
+			})
+		}
+	}
+}
+
+func formatHalfLife(survival *Survival, reference int64) string {
+	days, known := survival.HalfLife(reference)
+	if !known {
+		return "-"
+	}
+	if days >= 365 {
+		return fmt.Sprintf("%.1f years", days/365)
+	}
+	return fmt.Sprintf("%.0f days", days)
+}
+
+func survivalRow(name string, survival *Survival, reference int64) []interface{} {
+	written, alive := survival.TotalWritten(), survival.TotalAlive()
+	share := 0.0
+	if written > 0 {
+		share = float64(alive) * 100.0 / float64(written)
+	}
+	row := []interface{}{name, written, alive, fmt.Sprintf("%.1f%%", share)}
+	for _, lines := range survival.Histogram(reference) {
+		row = append(row, lines)
+	}
+	return append(row, formatHalfLife(survival, reference))
+}
+
+// PrintSurvival lists for each contributor the lines written and still
+// alive, the ages of the surviving ones and the half-life of their lines,
+// the contributors with the most surviving lines first
+func PrintSurvival(report *Report) {
+	var contributions []*Contribution
+	total := NewSurvival()
+	for _, contributor := range report.Contributors {
+		for _, contribution := range contributor.Contributions {
+			if contribution.Survival != nil {
+				contributions = append(contributions, contribution)
+				total.Merge(contribution.Survival)
+			}
+		}
+	}
+	if len(contributions) == 0 {
+		return
+	}
+	sort.Slice(contributions, func(i, j int) bool {
+		a, b := contributions[i].Survival.TotalAlive(), contributions[j].Survival.TotalAlive()
+		if a != b {
+			return a > b
+		}
+		return contributions[i].Name < contributions[j].Name
+	})
+	reference := total.latestDay()
+
+	fmt.Println("Code survival, the ages as of", time.Unix(reference*secondsPerDay, 0).UTC().Format("2006-01-02"))
+	table := termtables.CreateTable()
+	headers := []interface{}{"Contributor", "Written", "Alive", "Alive %"}
+	for _, ages := range survivalAges {
+		headers = append(headers, ages.Name)
+	}
+	table.AddHeaders(append(headers, "Half-life")...)
+	for _, contribution := range contributions {
+		table.AddRow(survivalRow(contribution.Name, contribution.Survival, reference)...)
+	}
+	table.AddSeparator()
+	table.AddRow(survivalRow("Total", total, reference)...)
+	for column := 2; column <= len(headers); column++ {
+		table.SetAlign(3, column)
+	}
+	fmt.Println(table.Render())
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHalfLife(t *testing.T) {
+	survival := NewSurvival()
+	survival.Written[100] = 100
+	survival.Alive[100] = 50
+	if days, known := survival.HalfLife(110); !known || math.Abs(days-10) > 0.01 {
+		t.Errorf("Half of the lines written 10 days ago being alive, the half-life should be 10 days, got %v", days)
+	}
+	survival.Alive[100] = 100
+	if _, known := survival.HalfLife(110); known {
+		t.Errorf("The half-life should be unknown when no line died")
+	}
+	survival.Alive = map[int64]int{}
+	if _, known := survival.HalfLife(110); known {
+		t.Errorf("The half-life should be unknown when no line survived")
+	}
+}
+
+func TestSurvivalHistogram(t *testing.T) {
+	survival := NewSurvival()
+	survival.Alive[1000] = 4
+	survival.Alive[900] = 2
+	survival.Alive[-1000] = 1
+	histogram := survival.Histogram(1010)
+	if histogram[0] != 4 || histogram[1] != 2 || histogram[len(histogram)-1] != 1 {
+		t.Errorf("Unexpected histogram %v", histogram)
+	}
+}
+
+func TestSurvivingFiles(t *testing.T) {
+	parser := NewParser("/", *NewPeriodArray(), *NewUserArray())
+	parser.ParseHistory(strings.NewReader("Contributor1|Mon May 30 22:08:53 2016 +0200\n\n5\t0\tsrc/main.c\n"))
+	parser.AddSurvivingFiles([]FileBlame{{Path: "src/main.c", Counts: BlameCounts{{Identity{Name: "Contributor1"}, 1464638933}: 3, {Identity{Name: "Contributor1"}, 0}: 1}}})
+	survival := parser.Report.Contributors["Contributor1"].Contributions[0].Survival
+	day := dayOf(time.Unix(1464638933, 0))
+	if survival == nil || survival.Written[day] != 5 || survival.TotalAlive() != 3 || survival.Alive[day] != 3 {
+		t.Errorf("The lines written and the ones alive should be recorded by day, got %v", survival)
+	}
+	if parser.Report.TotalAdditions != 5 {
+		t.Errorf("The surviving lines should not count as additions")
+	}
+}